@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// cartStore is the cart surface the handlers depend on. The real impl
+// (redisCart) keeps one redis hash per user; tests stub it.
+type cartStore interface {
+	List(ctx context.Context, user string) ([]cartItem, error)
+	Add(ctx context.Context, user, sku string, qty int64) (int64, error)
+	Remove(ctx context.Context, user, sku string) error
+	// Checkout empties the cart atomically and returns what was in it.
+	Checkout(ctx context.Context, user string) ([]cartItem, error)
+}
+
+type cartItem struct {
+	SKU string `json:"sku"`
+	Qty int64  `json:"qty"`
+}
+
+// cartTTL is how long an untouched cart survives.
+const cartTTL = 7 * 24 * time.Hour
+
+// errEmptyCart is Checkout on a cart with nothing in it.
+var errEmptyCart = errors.New("cart is empty")
+
+// redisCart stores cart:<user> as a hash of sku → quantity — the
+// backend ↔ redis edge the chain topology assumes.
+type redisCart struct{ rd *respClient }
+
+func cartKey(user string) string { return "cart:" + user }
+
+func (c *redisCart) List(ctx context.Context, user string) ([]cartItem, error) {
+	r, err := c.rd.Do(ctx, "HGETALL", cartKey(user))
+	if err != nil {
+		return nil, err
+	}
+	return hashItems(r)
+}
+
+func (c *redisCart) Add(ctx context.Context, user, sku string, qty int64) (int64, error) {
+	replies, err := c.rd.Pipeline(ctx,
+		[]string{"HINCRBY", cartKey(user), sku, strconv.FormatInt(qty, 10)},
+		[]string{"EXPIRE", cartKey(user), strconv.Itoa(int(cartTTL / time.Second))},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if replies[0].Kind == '-' {
+		return 0, &redisError{replies[0].Str}
+	}
+	n := replies[0].Int
+	if n <= 0 {
+		// A negative add that empties the line removes it.
+		if _, err := c.rd.Do(ctx, "HDEL", cartKey(user), sku); err != nil {
+			return 0, err
+		}
+		n = 0
+	}
+	return n, nil
+}
+
+func (c *redisCart) Remove(ctx context.Context, user, sku string) error {
+	_, err := c.rd.Do(ctx, "HDEL", cartKey(user), sku)
+	return err
+}
+
+func (c *redisCart) Checkout(ctx context.Context, user string) ([]cartItem, error) {
+	replies, err := c.rd.Pipeline(ctx,
+		[]string{"MULTI"},
+		[]string{"HGETALL", cartKey(user)},
+		[]string{"DEL", cartKey(user)},
+		[]string{"EXEC"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	exec := replies[len(replies)-1]
+	if exec.Kind == '-' {
+		return nil, &redisError{exec.Str}
+	}
+	if exec.Nil || len(exec.Elems) != 2 {
+		return nil, errors.New("checkout transaction aborted")
+	}
+	items, err := hashItems(exec.Elems[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errEmptyCart
+	}
+	return items, nil
+}
+
+// hashItems turns an HGETALL reply into items sorted by SKU.
+func hashItems(r redisReply) ([]cartItem, error) {
+	items := make([]cartItem, 0, len(r.Elems)/2)
+	for i := 0; i+1 < len(r.Elems); i += 2 {
+		n, err := strconv.ParseInt(r.Elems[i+1].Str, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cart line %q: %w", r.Elems[i].Str, err)
+		}
+		items = append(items, cartItem{SKU: r.Elems[i].Str, Qty: n})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].SKU < items[j].SKU })
+	return items, nil
+}
+
+// registerCartRoutes wires the per-user cart:
+//
+//	GET    /api/cart/{user}              → {"user","items":[{"sku","qty"}]}
+//	POST   /api/cart/{user}/items        {"sku","qty"} → {"sku","qty"}
+//	DELETE /api/cart/{user}/items/{sku}
+//	POST   /api/cart/{user}/checkout     → {"user","items"}, cart emptied
+func registerCartRoutes(mux *http.ServeMux, cart cartStore) {
+	cartJSON := func(w http.ResponseWriter, status int, v any) {
+		b, _ := json.Marshal(v)
+		writeJSON(w, status, string(b))
+	}
+	cartErr := func(w http.ResponseWriter, err error) {
+		cartJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
+
+	mux.HandleFunc("GET /api/cart/{user}", func(w http.ResponseWriter, r *http.Request) {
+		items, err := cart.List(r.Context(), r.PathValue("user"))
+		if err != nil {
+			cartErr(w, err)
+			return
+		}
+		cartJSON(w, http.StatusOK, map[string]any{"user": r.PathValue("user"), "items": items})
+	})
+
+	mux.HandleFunc("POST /api/cart/{user}/items", func(w http.ResponseWriter, r *http.Request) {
+		var req cartItem
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.SKU == "" {
+			http.Error(w, "sku is required", http.StatusBadRequest)
+			return
+		}
+		if req.Qty == 0 {
+			req.Qty = 1
+		}
+		n, err := cart.Add(r.Context(), r.PathValue("user"), req.SKU, req.Qty)
+		if err != nil {
+			cartErr(w, err)
+			return
+		}
+		cartJSON(w, http.StatusOK, cartItem{SKU: req.SKU, Qty: n})
+	})
+
+	mux.HandleFunc("DELETE /api/cart/{user}/items/{sku}", func(w http.ResponseWriter, r *http.Request) {
+		if err := cart.Remove(r.Context(), r.PathValue("user"), r.PathValue("sku")); err != nil {
+			cartErr(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST /api/cart/{user}/checkout", func(w http.ResponseWriter, r *http.Request) {
+		items, err := cart.Checkout(r.Context(), r.PathValue("user"))
+		if errors.Is(err, errEmptyCart) {
+			cartJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			cartErr(w, err)
+			return
+		}
+		cartJSON(w, http.StatusOK, map[string]any{"user": r.PathValue("user"), "items": items})
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis accepts one connection, answers each of len(replies)
+// multibulk commands with the next reply and sends back what it read.
+func fakeRedis(t *testing.T, replies ...string) (string, <-chan [][]string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	seen := make(chan [][]string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		var cmds [][]string
+		for range replies {
+			hdr, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(hdr[1:]))
+			cmd := make([]string, n)
+			for i := range cmd {
+				_, _ = br.ReadString('\n')
+				a, _ := br.ReadString('\n')
+				cmd[i] = strings.TrimSuffix(a, "\r\n")
+			}
+			cmds = append(cmds, cmd)
+			_, _ = io.WriteString(conn, replies[len(cmds)-1])
+		}
+		seen <- cmds
+	}()
+	return ln.Addr().String(), seen
+}
+
+// TestRedisCart_CheckoutIsOneTransaction pins the checkout shape: the
+// read and the delete go out as MULTI / HGETALL / DEL / EXEC, so two
+// concurrent checkouts can't both ship the same cart.
+func TestRedisCart_CheckoutIsOneTransaction(t *testing.T) {
+	addr, seen := fakeRedis(t,
+		"+OK\r\n", "+QUEUED\r\n", "+QUEUED\r\n",
+		"*2\r\n*4\r\n$5\r\nsku-2\r\n$1\r\n1\r\n$5\r\nsku-1\r\n$1\r\n3\r\n:1\r\n")
+	cart := &redisCart{rd: newRespClient(addr, "", "", time.Second)}
+
+	items, err := cart.Checkout(context.Background(), "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != (cartItem{"sku-1", 3}) || items[1] != (cartItem{"sku-2", 1}) {
+		t.Errorf("items = %+v, want sorted by sku", items)
+	}
+	var verbs []string
+	for _, c := range <-seen {
+		verbs = append(verbs, strings.Join(c, " "))
+	}
+	if got := strings.Join(verbs, " | "); got != "MULTI | HGETALL cart:42 | DEL cart:42 | EXEC" {
+		t.Errorf("wire = %s", got)
+	}
+}
+
+func TestRedisCart_AddIncrementsHash(t *testing.T) {
+	addr, seen := fakeRedis(t, ":2\r\n", ":1\r\n")
+	cart := &redisCart{rd: newRespClient(addr, "", "", time.Second)}
+	if n, err := cart.Add(context.Background(), "42", "sku-1", 2); err != nil || n != 2 {
+		t.Fatalf("Add = %d, %v", n, err)
+	}
+	cmds := <-seen
+	if strings.Join(cmds[0], " ") != "HINCRBY cart:42 sku-1 2" || cmds[1][0] != "EXPIRE" {
+		t.Errorf("wire = %q", cmds)
+	}
+}
+
+// TestRespClient_AuthenticatesAsACLUser pins the chain-hardened.yaml
+// shape: with `user default off`, a fresh connection must AUTH as the
+// named user before its first command.
+func TestRespClient_AuthenticatesAsACLUser(t *testing.T) {
+	addr, seen := fakeRedis(t, "+OK\r\n", "$1\r\n3\r\n")
+	rd := newRespClient(addr, "chain", "s3cret", time.Second)
+	if _, err := rd.Do(context.Background(), "HGET", "cart:42", "sku-1"); err != nil {
+		t.Fatal(err)
+	}
+	cmds := <-seen
+	if strings.Join(cmds[0], " ") != "AUTH chain s3cret" {
+		t.Errorf("first command = %q", cmds[0])
+	}
+}
+
+// stubCart is an in-memory cartStore.
+type stubCart struct{ items map[string]map[string]int64 }
+
+func (s *stubCart) List(_ context.Context, user string) ([]cartItem, error) {
+	var out []cartItem
+	for sku, n := range s.items[user] {
+		out = append(out, cartItem{sku, n})
+	}
+	return out, nil
+}
+
+func (s *stubCart) Add(_ context.Context, user, sku string, qty int64) (int64, error) {
+	if s.items[user] == nil {
+		s.items[user] = map[string]int64{}
+	}
+	s.items[user][sku] += qty
+	return s.items[user][sku], nil
+}
+
+func (s *stubCart) Remove(_ context.Context, user, sku string) error {
+	delete(s.items[user], sku)
+	return nil
+}
+
+func (s *stubCart) Checkout(ctx context.Context, user string) ([]cartItem, error) {
+	items, _ := s.List(ctx, user)
+	if len(items) == 0 {
+		return nil, errEmptyCart
+	}
+	delete(s.items, user)
+	return items, nil
+}
+
+func TestCartRoutes(t *testing.T) {
+	srv := newServer(nil, nil, withCart(&stubCart{items: map[string]map[string]int64{}}))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/cart/42/items", `{"sku":"sku-1","qty":2}`); rec.Body.String() != `{"sku":"sku-1","qty":2}` {
+		t.Errorf("add = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/cart/42", ""); rec.Body.String() != `{"items":[{"sku":"sku-1","qty":2}],"user":"42"}` {
+		t.Errorf("list = %s", rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/cart/42/checkout", ""); rec.Code != http.StatusOK {
+		t.Errorf("checkout = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/cart/42/checkout", ""); rec.Code != http.StatusConflict {
+		t.Errorf("second checkout = %d, want 409 for an empty cart", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/cart/42/items", `{"qty":1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("add without sku = %d, want 400", rec.Code)
+	}
+}
@@ -13,21 +13,28 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	// pure-Go postgres driver. Lives in the chain-backend's isolated
 	// go.mod (not pkg/), so adding this driver does not touch the
 	// bobctl codebase.
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // executor is the SQL surface the handlers depend on. The real impl
 // (pgExecutor) wraps database/sql; tests use a stub that records the
 // last SQL forwarded so we can assert "raw user input reached the DB".
+// args bind to $1, $2, … placeholders; the admin sink passes none.
+// ctx is the request's, bounded by statementContext: when the client
+// hangs up or the endpoint's timeout passes, the query is cancelled
+// server-side.
 type executor interface {
-	Exec(sql string) (string, error)
+	Exec(ctx context.Context, sql string, args ...any) (string, error)
 }
 
 // fetcher is the HTTP-GET surface for the SSRF endpoint. Real impl
@@ -37,9 +44,34 @@ type fetcher interface {
 	Get(url string) (int, string, error)
 }
 
+// serverConfig carries newServer's optional wiring.
+type serverConfig struct {
+	cart cartStore
+
+	productsTimeout time.Duration
+	adminTimeout    time.Duration
+}
+
+type serverOption func(*serverConfig)
+
+// withCart serves /api/cart/ from a real cart store.
+func withCart(c cartStore) serverOption {
+	return func(cfg *serverConfig) { cfg.cart = c }
+}
+
+// withStatementTimeouts sets the statement_timeout for /api/products
+// and /api/admin/sql queries; 0 leaves that endpoint unbounded.
+func withStatementTimeouts(products, admin time.Duration) serverOption {
+	return func(cfg *serverConfig) { cfg.productsTimeout, cfg.adminTimeout = products, admin }
+}
+
 // newServer wires the handlers onto a mux. Both deps may be nil in
 // tests that only exercise endpoints which don't use them (e.g. healthz).
-func newServer(exec executor, fetch fetcher) http.Handler {
+func newServer(exec executor, fetch fetcher, opts ...serverOption) http.Handler {
+	cfg := serverConfig{}
+	for _, o := range opts {
+		o(&cfg)
+	}
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -50,17 +82,33 @@ func newServer(exec executor, fetch fetcher) http.Handler {
 	// Benign baseline endpoint — protocol_loadtest_server hammers this
 	// during the learn phase to populate (a) backend's NetworkNeighborhood
 	// edge to postgres and (b) postgres's normal-traffic profile.
+	//
+	// VULNERABLE — unauthenticated sqli entry. q, page and per_page are
+	// bound as parameters and dir is whitelisted, but sort is spliced
+	// into ORDER BY as-is: a column name can't be a bind parameter, so
+	// this is the injection that survives prepared statements
+	// (?sort=(SELECT CASE WHEN … THEN id ELSE name END)).
 	mux.HandleFunc("/api/products", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, must(exec.Exec(
-			"SELECT id, name FROM products LIMIT 50")))
+		query, args, err := productsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := statementContext(r.Context(), cfg.productsTimeout)
+		defer cancel()
+		writeJSON(w, http.StatusOK, must(exec.Exec(ctx, query, args...)))
 	})
 
-	// Benign baseline — backend ↔ redis edge. We don't have a real redis
-	// client here (kept zero-dep on purpose); the handler just hits the
-	// SQL exec for cache-miss and returns canned JSON.
-	mux.HandleFunc("/api/cart/", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, `{"items":[]}`)
-	})
+	// Benign baseline — backend ↔ redis edge. The cart lives in redis
+	// hashes (cart.go, over the zero-dep client in redis.go); without a
+	// store configured every cart is empty.
+	if cfg.cart != nil {
+		registerCartRoutes(mux, cfg.cart)
+	} else {
+		mux.HandleFunc("/api/cart/", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, `{"items":[]}`)
+		})
+	}
 
 	// VULNERABLE — scenario 2 + 3 entry. Splices request.q into the SQL
 	// string with NO parameterisation. This is the intentional sink for
@@ -75,8 +123,23 @@ func newServer(exec executor, fetch fetcher) http.Handler {
 			http.Error(w, "q is required", http.StatusBadRequest)
 			return
 		}
-		// Splice as-is. This is the demo's whole point.
-		out, err := exec.Exec(req.Q)
+		// Splice as-is. This is the demo's whole point. A querier gets
+		// the typed result (query.go): column OIDs, command tags, NOTICE
+		// output and every result set — where COPY … FROM PROGRAM and
+		// RAISE NOTICE exfil actually shows up.
+		//
+		// The admin timeout bounds pg_sleep-style payloads, so a
+		// time-based blind stage measures a known ceiling rather than a
+		// pinned connection.
+		ctx, cancel := statementContext(r.Context(), cfg.adminTimeout)
+		defer cancel()
+		if q, ok := exec.(querier); ok {
+			res, _ := q.Query(ctx, req.Q)
+			b, _ := json.Marshal(res)
+			writeJSON(w, http.StatusOK, string(b))
+			return
+		}
+		out, err := exec.Exec(ctx, req.Q)
 		if err != nil {
 			// Return 200 with error body so the runner sees the postgres
 			// error message (helps debugging) without classifying the
@@ -113,6 +176,51 @@ func newServer(exec executor, fetch fetcher) http.Handler {
 	return mux
 }
 
+// productsQuery builds the /api/products SELECT from q (name
+// substring), page, per_page (default 50, max 100), sort (default id)
+// and dir (asc|desc).
+func productsQuery(v url.Values) (string, []any, error) {
+	page, perPage := 1, 50
+	if s := v.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return "", nil, fmt.Errorf("page must be a positive integer")
+		}
+		page = n
+	}
+	if s := v.Get("per_page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			return "", nil, fmt.Errorf("per_page must be 1-100")
+		}
+		perPage = n
+	}
+	dir := "ASC"
+	switch strings.ToLower(v.Get("dir")) {
+	case "", "asc":
+	case "desc":
+		dir = "DESC"
+	default:
+		return "", nil, fmt.Errorf("dir must be asc or desc")
+	}
+	orderBy := v.Get("sort")
+	if orderBy == "" {
+		orderBy = "id"
+	}
+
+	query := "SELECT id, name FROM products"
+	var args []any
+	if q := v.Get("q"); q != "" {
+		args = append(args, "%"+q+"%")
+		query += " WHERE name ILIKE $1"
+	}
+	// Splice sort as-is. This is the sink.
+	query += " ORDER BY " + orderBy + " " + dir
+	args = append(args, perPage, (page-1)*perPage)
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	return query, args, nil
+}
+
 // writeJSON sends a response with explicit content-type. Body strings
 // that already look like JSON go through verbatim; non-JSON gets
 // quoted into a {"data": ...} envelope so callers always get parseable
@@ -141,11 +249,35 @@ func must(s string, err error) string {
 
 // pgExecutor wraps database/sql so the handlers can use the executor
 // interface. We deliberately keep this in the same file: the chain
-// backend has no production lifetime — it's a demo target.
-type pgExecutor struct{ db *sql.DB }
+// backend has no production lifetime — it's a demo target. Its typed
+// /api/admin/sql path (Query) sits with the result types in query.go.
+type pgExecutor struct {
+	// db has no statement_timeout; migrations run on it.
+	db  *sql.DB
+	cfg pq.Config
+
+	mu    sync.Mutex
+	pools map[time.Duration]*sql.DB
+}
 
-func (p *pgExecutor) Exec(query string) (string, error) {
-	rows, err := p.db.Query(query)
+func newPgExecutor(dsn string) (*pgExecutor, error) {
+	cfg, err := pq.NewConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pq.NewConnectorConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &pgExecutor{db: sql.OpenDB(conn), cfg: cfg, pools: map[time.Duration]*sql.DB{}}, nil
+}
+
+func (p *pgExecutor) Exec(ctx context.Context, query string, args ...any) (string, error) {
+	db, err := p.pool(ctx)
+	if err != nil {
+		return "", err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return "", err
 	}
@@ -154,6 +286,7 @@ func (p *pgExecutor) Exec(query string) (string, error) {
 	if err != nil {
 		return "[]", nil
 	}
+	types, _ := rows.ColumnTypes()
 	var out []map[string]any
 	for rows.Next() {
 		vals := make([]any, len(cols))
@@ -166,10 +299,19 @@ func (p *pgExecutor) Exec(query string) (string, error) {
 		}
 		row := make(map[string]any, len(cols))
 		for i, c := range cols {
-			row[c] = vals[i]
+			var typ string
+			if i < len(types) {
+				typ = types[i].DatabaseTypeName()
+			}
+			row[c] = jsonValue(vals[i], typ)
 		}
 		out = append(out, row)
 	}
+	// A statement_timeout or cancel partway through ends Next early;
+	// the rows read so far are not the answer.
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
 	b, _ := json.Marshal(out)
 	return string(b), nil
 }
@@ -201,17 +343,75 @@ func main() {
 
 	// Lazy connect — we want backend to start even if postgres is briefly
 	// unavailable, so the readiness probe can flip green on its own clock.
-	db, err := sql.Open("postgres", pgConn)
+	exec, err := newPgExecutor(pgConn)
 	if err != nil {
 		// Don't fatal — start the listener so /healthz can flip.
-		log.Printf("WARN: sql.Open failed: %v (handlers will return 500)", err)
+		log.Printf("WARN: postgres DSN: %v (handlers will return errors)", err)
+		exec = &pgExecutor{}
+	}
+	db := exec.db
+
+	// Schema + demo data, so a fresh `kubectl apply -f chain.yaml` serves
+	// /api/products without anyone seeding postgres by hand. Runs in the
+	// background and retries until postgres answers; replicas serialise
+	// on an advisory lock. MIGRATE=false / SEED=false opt out.
+	if db != nil && getenv("MIGRATE", "true") == "true" {
+		var extra []migration
+		if getenv("SEED", "true") == "true" {
+			extra = append(extra, seedMigration(seedVersion))
+		}
+		migrations, err := loadMigrations(extra...)
+		if err != nil {
+			log.Fatalf("migrations: %v", err)
+		}
+		go migrateUntilReady(context.Background(), db, migrations)
 	}
-	exec := &pgExecutor{db: db}
 	fetch := &httpFetcher{client: &http.Client{Timeout: 5 * time.Second}}
 
+	// Credentials for chain-redis, shared by the registry and the cart
+	// and named like chain-frontend's: REDIS_USERNAME for an ACL user
+	// (chain-hardened.yaml turns the default user off), REDIS_PASSWORD
+	// or REDIS_PASSWORD_FILE for its password.
+	redisUser, redisPass := os.Getenv("REDIS_USERNAME"), os.Getenv("REDIS_PASSWORD")
+	if f := os.Getenv("REDIS_PASSWORD_FILE"); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			log.Fatalf("REDIS_PASSWORD_FILE: %v", err)
+		}
+		redisPass = strings.TrimSpace(string(b))
+	}
+
+	// Optional service discovery: announce this replica in redis so
+	// chain-frontend (BACKEND_DISCOVERY=redis) can balance across
+	// replicas instead of going through the Service.
+	if ra := os.Getenv("REGISTRY_REDIS_ADDR"); ra != "" {
+		ttl, err := time.ParseDuration(getenv("REGISTRY_TTL", "15s"))
+		if err != nil || ttl <= 0 {
+			log.Fatalf("REGISTRY_TTL: must be a positive duration")
+		}
+		reg := &registrar{rd: newRespClient(ra, redisUser, redisPass, 5*time.Second), self: advertiseAddr(addr), ttl: ttl}
+		go reg.run(context.Background())
+		log.Printf("registering %s in %s at %s (ttl %s)", reg.self, registryKey, ra, ttl)
+	}
+
+	// The cart's redis; like postgres, it is dialled lazily.
+	cart := &redisCart{rd: newRespClient(getenv("REDIS_ADDR", "chain-redis.chain.svc:6379"),
+		redisUser, redisPass, 5*time.Second)}
+
+	// Per-endpoint statement_timeout. The admin sink gets longer so
+	// pg_sleep-based stages have room; 0 disables either.
+	productsTimeout, err := time.ParseDuration(getenv("PRODUCTS_STATEMENT_TIMEOUT", "5s"))
+	if err != nil || productsTimeout < 0 {
+		log.Fatalf("PRODUCTS_STATEMENT_TIMEOUT: must be a duration >= 0")
+	}
+	adminTimeout, err := time.ParseDuration(getenv("ADMIN_STATEMENT_TIMEOUT", "30s"))
+	if err != nil || adminTimeout < 0 {
+		log.Fatalf("ADMIN_STATEMENT_TIMEOUT: must be a duration >= 0")
+	}
+
 	srv := &http.Server{
 		Addr:              addr,
-		Handler:           newServer(exec, fetch),
+		Handler:           newServer(exec, fetch, withCart(cart), withStatementTimeouts(productsTimeout, adminTimeout)),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	log.Printf("chain-backend listening on %s", addr)
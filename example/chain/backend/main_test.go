@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -15,15 +18,23 @@ import (
 // as written here — that's how the chain-attacks.yaml gets its bite.
 
 // stubExecutor lets tests verify which SQL was forwarded to "postgres"
-// without needing a real database. Returns canned (rows, err).
+// without needing a real database. Returns canned (rows, err); with
+// block set it behaves like pg_sleep(∞) and returns only when ctx ends.
 type stubExecutor struct {
-	lastSQL string
-	rows    string
-	err     error
+	lastSQL  string
+	lastArgs []any
+	lastCtx  context.Context
+	rows     string
+	err      error
+	block    bool
 }
 
-func (s *stubExecutor) Exec(sql string) (string, error) {
-	s.lastSQL = sql
+func (s *stubExecutor) Exec(ctx context.Context, sql string, args ...any) (string, error) {
+	s.lastCtx, s.lastSQL, s.lastArgs = ctx, sql, args
+	if s.block {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
 	return s.rows, s.err
 }
 
@@ -152,6 +163,50 @@ func TestProducts_BenignSelect(t *testing.T) {
 	}
 }
 
+// TestProducts_SortSplicedIntoOrderBy is the GET sqli contract: sort
+// MUST reach ORDER BY verbatim. Whitelisting columns here closes the
+// unauthenticated sqli entry the attack suite relies on.
+func TestProducts_SortSplicedIntoOrderBy(t *testing.T) {
+	exec := &stubExecutor{rows: "[]"}
+	srv := newServer(exec, nil)
+	evil := "(SELECT CASE WHEN current_user='postgres' THEN id ELSE name END)"
+	req := httptest.NewRequest(http.MethodGet, "/api/products?sort="+url.QueryEscape(evil), nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if !strings.Contains(exec.lastSQL, "ORDER BY "+evil+" ASC") {
+		t.Errorf("sort was rewritten — backend MUST splice it. lastSQL=%q", exec.lastSQL)
+	}
+}
+
+// TestProducts_FilterAndPagingAreBound: everything but sort goes
+// through placeholders, so the sink stays the one parameter.
+func TestProducts_FilterAndPagingAreBound(t *testing.T) {
+	exec := &stubExecutor{rows: "[]"}
+	srv := newServer(exec, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/products?q=st'ick&page=3&per_page=10&sort=name&dir=desc", nil)
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := "SELECT id, name FROM products WHERE name ILIKE $1 ORDER BY name DESC LIMIT $2 OFFSET $3"
+	if exec.lastSQL != want {
+		t.Errorf("sql = %q, want %q", exec.lastSQL, want)
+	}
+	if fmt.Sprint(exec.lastArgs) != "[%st'ick% 10 20]" {
+		t.Errorf("args = %v", exec.lastArgs)
+	}
+
+	for _, q := range []string{"page=0", "per_page=500", "dir=sideways"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/products?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, rec.Code)
+		}
+	}
+}
+
 // TestEndpointsReturnJSON_ContentType pins the response content-type
 // so test-pod curl + protocol_loadtest_server agree on parsing.
 func TestEndpointsReturnJSON_ContentType(t *testing.T) {
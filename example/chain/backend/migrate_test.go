@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations_OrderedWithSeedLast(t *testing.T) {
+	ms, err := loadMigrations(seedMigration(seedVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range ms {
+		got = append(got, m.name)
+	}
+	want := []string{"products", "customers_orders", "api_keys", "seed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	if !strings.Contains(ms[0].sql, "CREATE TABLE IF NOT EXISTS products") {
+		t.Errorf("0001 sql = %q", ms[0].sql)
+	}
+	if _, err := loadMigrations(migration{version: 1, name: "clash"}); err == nil {
+		t.Error("duplicate version accepted")
+	}
+}
+
+// TestSeedData_Deterministic pins that every replica and every fresh
+// cluster seeds the same rows — attack-results diffs rely on it.
+func TestSeedData_Deterministic(t *testing.T) {
+	a, b := seedData(), seedData()
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("two seedData runs differ")
+	}
+	if len(a.Products) != seedProducts || len(a.Customers) != seedCustomers || len(a.Orders) != seedOrders || len(a.APIKeys) == 0 {
+		t.Fatalf("sizes = %d/%d/%d/%d", len(a.Products), len(a.Customers), len(a.Orders), len(a.APIKeys))
+	}
+	emails := map[string]bool{}
+	for _, c := range a.Customers {
+		if c.SSN[0] != '9' || !strings.HasSuffix(c.Email, "@example.com") {
+			t.Errorf("customer %+v is not synthetic", c)
+		}
+		if emails[c.Email] {
+			t.Errorf("duplicate email %s", c.Email)
+		}
+		emails[c.Email] = true
+	}
+	for _, o := range a.Orders {
+		if o.CustomerID < 1 || o.CustomerID > seedCustomers || o.ProductID < 1 || o.ProductID > seedProducts {
+			t.Fatalf("order %+v references a missing row", o)
+		}
+	}
+	for _, k := range a.APIKeys {
+		if !strings.HasPrefix(k.Key, "sk_live_") || len(k.Key) != len("sk_live_")+32 {
+			t.Errorf("key = %q", k.Key)
+		}
+	}
+}
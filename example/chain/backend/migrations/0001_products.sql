@@ -0,0 +1,12 @@
+-- Catalog read by GET /api/products.
+CREATE TABLE IF NOT EXISTS products (
+    id          serial PRIMARY KEY,
+    name        text    NOT NULL,
+    price_cents integer NOT NULL DEFAULT 0,
+    stock       integer NOT NULL DEFAULT 0
+);
+-- A products table seeded by hand before migrations existed may only
+-- have id and name.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price_cents integer NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stock integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS products_name_idx ON products (name);
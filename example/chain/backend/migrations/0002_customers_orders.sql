@@ -0,0 +1,21 @@
+-- Customers carry synthetic PII (see seed.go) so exfil stages leak
+-- rows that look like the real thing.
+CREATE TABLE IF NOT EXISTS customers (
+    id         serial PRIMARY KEY,
+    name       text NOT NULL,
+    email      text NOT NULL UNIQUE,
+    phone      text NOT NULL,
+    address    text NOT NULL,
+    ssn        text NOT NULL,
+    card_last4 text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id          serial PRIMARY KEY,
+    customer_id integer NOT NULL REFERENCES customers (id),
+    product_id  integer NOT NULL REFERENCES products (id),
+    qty         integer NOT NULL,
+    total_cents integer NOT NULL,
+    created_at  timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS orders_customer_idx ON orders (customer_id);
@@ -0,0 +1,8 @@
+-- Per-customer API keys, stored in the clear the way too many shops do.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id          serial PRIMARY KEY,
+    customer_id integer NOT NULL REFERENCES customers (id),
+    key         text    NOT NULL UNIQUE,
+    scopes      text    NOT NULL,
+    created_at  timestamptz NOT NULL
+);
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/lib/pq/oid"
+)
+
+// querier is the optional typed-result surface /api/admin/sql prefers
+// over executor.Exec. pgExecutor implements it; stubs that don't fall
+// back to Exec's flat row list.
+type querier interface {
+	Query(ctx context.Context, sql string) (*queryResult, error)
+}
+
+// queryResult is everything postgres said about one /api/admin/sql
+// call: one entry per result set of a multi-statement query, plus the
+// NOTICE/WARNING messages raised while it ran. Query always returns
+// one; on failure it holds whatever arrived before the error, with
+// Error set.
+type queryResult struct {
+	Results []resultSet `json:"results"`
+	Notices []notice    `json:"notices"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// resultSet is one statement's output. Command is lib/pq's tag without
+// the row count ("SELECT", "INSERT", "COPY", "CREATE TABLE").
+type resultSet struct {
+	Columns      []column `json:"columns"`
+	Rows         [][]any  `json:"rows"`
+	Command      string   `json:"command,omitempty"`
+	RowsAffected int64    `json:"rows_affected"`
+}
+
+type column struct {
+	Name string  `json:"name"`
+	Type string  `json:"type"`
+	OID  oid.Oid `json:"oid"`
+}
+
+type notice struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+	Where    string `json:"where,omitempty"`
+}
+
+// typeOID inverts lib/pq's OID → name table; ColumnTypeDatabaseTypeName
+// only gives us the name.
+var typeOID = func() map[string]oid.Oid {
+	m := make(map[string]oid.Oid, len(oid.TypeName))
+	for o, name := range oid.TypeName {
+		m[name] = o
+	}
+	return m
+}()
+
+// Query runs sql on one pinned connection at the driver level, which
+// is the only place lib/pq exposes command tags, and routes that
+// connection's notices into the result while it runs. sql goes over
+// the simple-query protocol, so "stmt; stmt; …" yields one result set
+// per row-returning statement. lib/pq folds a row-less statement into
+// the set after it, so only the last tag of such a run survives.
+func (p *pgExecutor) Query(ctx context.Context, sql string) (*queryResult, error) {
+	res := &queryResult{Results: []resultSet{}, Notices: []notice{}}
+	db, err := p.pool(ctx)
+	if err != nil {
+		res.Error = err.Error()
+		return res, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		res.Error = err.Error()
+		return res, err
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(dc any) error {
+		pc := dc.(driver.Conn)
+		pq.SetNoticeHandler(pc, func(e *pq.Error) {
+			res.Notices = append(res.Notices, notice{
+				Severity: e.Severity, Code: string(e.Code), Message: e.Message,
+				Detail: e.Detail, Hint: e.Hint, Where: e.Where,
+			})
+		})
+		defer pq.SetNoticeHandler(pc, nil)
+
+		rows, err := dc.(driver.QueryerContext).QueryContext(ctx, sql, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		res.Results, err = collectResults(rows)
+		return err
+	})
+	if err != nil {
+		res.Error = err.Error()
+		return res, err
+	}
+	return res, nil
+}
+
+// statementGrace is how long past statement_timeout the request
+// context lives. Normally postgres gives up first and reports 57014;
+// the context is the backstop for a payload that SETs its own timeout.
+const statementGrace = time.Second
+
+type statementTimeoutKey struct{}
+
+// statementContext derives the context one endpoint's query runs
+// under. It carries d for pgExecutor.pool to apply as statement_timeout
+// and expires d+statementGrace after the request started; lib/pq sends
+// a CancelRequest when it does, and equally when the client hangs up
+// and net/http cancels the parent.
+func statementContext(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	ctx, cancel := context.WithTimeout(parent, d+statementGrace)
+	return context.WithValue(ctx, statementTimeoutKey{}, d), cancel
+}
+
+// statementTimeout is the timeout statementContext stored in ctx, or 0.
+func statementTimeout(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// pool returns the connection pool for ctx's statement timeout. Each
+// distinct timeout (one per endpoint) gets its own pool whose
+// connections carry statement_timeout as a startup parameter, so no
+// query pays an extra SET round trip and no timeout outlives its
+// endpoint on a shared connection.
+func (p *pgExecutor) pool(ctx context.Context) (*sql.DB, error) {
+	if p.db == nil {
+		return nil, errors.New("postgres is not configured")
+	}
+	d := statementTimeout(ctx)
+	if d <= 0 {
+		return p.db, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if db, ok := p.pools[d]; ok {
+		return db, nil
+	}
+	cfg := p.cfg.Clone()
+	if cfg.Runtime == nil {
+		cfg.Runtime = map[string]string{}
+	}
+	cfg.Runtime["statement_timeout"] = strconv.FormatInt(d.Milliseconds(), 10)
+	conn, err := pq.NewConnectorConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(conn)
+	p.pools[d] = db
+	return db, nil
+}
+
+// collectResults drains every result set of rows.
+func collectResults(rows driver.Rows) ([]resultSet, error) {
+	sets := []resultSet{}
+	for {
+		names := rows.Columns()
+		set := resultSet{Columns: make([]column, len(names)), Rows: [][]any{}}
+		for i, name := range names {
+			set.Columns[i].Name = name
+			if ct, ok := rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+				set.Columns[i].Type = ct.ColumnTypeDatabaseTypeName(i)
+				set.Columns[i].OID = typeOID[set.Columns[i].Type]
+			}
+		}
+		dest := make([]driver.Value, len(names))
+		for {
+			err := rows.Next(dest)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return append(sets, set), err
+			}
+			row := make([]any, len(dest))
+			for i, v := range dest {
+				row[i] = jsonValue(v, set.Columns[i].Type)
+			}
+			set.Rows = append(set.Rows, row)
+		}
+		// Next has consumed this set's CommandComplete by now.
+		if rt, ok := rows.(interface {
+			Result() driver.Result
+			Tag() string
+		}); ok {
+			set.Command = rt.Tag()
+			set.RowsAffected, _ = rt.Result().RowsAffected()
+		}
+		sets = append(sets, set)
+
+		nrs, ok := rows.(driver.RowsNextResultSet)
+		if !ok || !nrs.HasNextResultSet() {
+			return sets, nil
+		}
+		if err := nrs.NextResultSet(); err != nil {
+			return sets, err
+		}
+	}
+}
+
+// jsonValue makes a driver value JSON-friendly without json.Marshal's
+// base64 for []byte: bytea renders the way psql shows it (\x…), and
+// every other type lib/pq leaves as raw bytes (numeric, uuid, json,
+// arrays, …) is already text.
+func jsonValue(v driver.Value, typ string) any {
+	switch v := v.(type) {
+	case []byte:
+		if typ == "BYTEA" {
+			return `\x` + hex.EncodeToString(v)
+		}
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case nil, int64, float64, bool, string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
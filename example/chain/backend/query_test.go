@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRows replays result sets the way lib/pq's rows does: Next walks
+// one set, then Tag/Result describe it until NextResultSet moves on.
+// A non-nil err is returned in place of io.EOF once the rows run out,
+// the way a statement_timeout lands partway through a result.
+type fakeRows struct {
+	sets []fakeSet
+	i, n int
+	err  error
+}
+
+type fakeSet struct {
+	cols, types []string
+	rows        [][]driver.Value
+	tag         string
+	affected    int64
+}
+
+func (f *fakeRows) Columns() []string { return f.sets[f.i].cols }
+func (f *fakeRows) Close() error      { return nil }
+
+func (f *fakeRows) Next(dest []driver.Value) error {
+	s := f.sets[f.i]
+	if f.n >= len(s.rows) {
+		if f.err != nil {
+			return f.err
+		}
+		return io.EOF
+	}
+	copy(dest, s.rows[f.n])
+	f.n++
+	return nil
+}
+
+func (f *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return f.sets[f.i].types[i] }
+func (f *fakeRows) HasNextResultSet() bool                  { return f.i+1 < len(f.sets) }
+func (f *fakeRows) NextResultSet() error                    { f.i, f.n = f.i+1, 0; return nil }
+func (f *fakeRows) Tag() string                             { return f.sets[f.i].tag }
+func (f *fakeRows) Result() driver.Result                   { return driver.RowsAffected(f.sets[f.i].affected) }
+
+// fakeConn hands every query rows through database/sql, so pgExecutor
+// can run without postgres.
+type fakeConn struct{ rows *fakeRows }
+
+func (c fakeConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c fakeConn) Driver() driver.Driver                        { return nil }
+func (c fakeConn) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                                 { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+func (c fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return c.rows, nil
+}
+
+// TestPgExecutorExec_RowErrorFailsQuery pins that an error partway
+// through the rows fails Exec instead of answering with the rows read
+// before it.
+func TestPgExecutorExec_RowErrorFailsQuery(t *testing.T) {
+	rows := &fakeRows{
+		sets: []fakeSet{{cols: []string{"id"}, types: []string{"INT4"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}},
+		err:  errors.New("pq: canceling statement due to statement timeout"),
+	}
+	p := &pgExecutor{db: sql.OpenDB(fakeConn{rows})}
+	defer p.db.Close()
+	if out, err := p.Exec(context.Background(), "SELECT id FROM products"); err == nil || !strings.Contains(err.Error(), "statement timeout") {
+		t.Fatalf("Exec = %q, %v; want the timeout error", out, err)
+	}
+}
+
+func TestCollectResults_TypedMultiSet(t *testing.T) {
+	rows := &fakeRows{sets: []fakeSet{
+		{
+			cols: []string{"id", "blob", "note"}, types: []string{"INT4", "BYTEA", "TEXT"},
+			rows: [][]driver.Value{{int64(1), []byte{0xde, 0xad}, "hi"}}, tag: "SELECT", affected: 1,
+		},
+		{cols: nil, tag: "COPY", affected: 3},
+	}}
+	sets, err := collectResults(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(sets)
+	want := `[{"columns":[{"name":"id","type":"INT4","oid":23},{"name":"blob","type":"BYTEA","oid":17},{"name":"note","type":"TEXT","oid":25}],` +
+		`"rows":[[1,"\\xdead","hi"]],"command":"SELECT","rows_affected":1},` +
+		`{"columns":[],"rows":[],"command":"COPY","rows_affected":3}]`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+}
+
+// stubQuerier returns a canned typed result.
+type stubQuerier struct {
+	stubExecutor
+	res *queryResult
+}
+
+func (s *stubQuerier) Query(_ context.Context, sql string) (*queryResult, error) {
+	s.lastSQL = sql
+	return s.res, nil
+}
+
+// TestAdminSQL_TypedResultCarriesNotices pins that RAISE NOTICE output
+// reaches the caller — a DO block's only channel back out.
+func TestAdminSQL_TypedResultCarriesNotices(t *testing.T) {
+	q := &stubQuerier{res: &queryResult{
+		Results: []resultSet{{Columns: []column{}, Rows: [][]any{}, Command: "DO"}},
+		Notices: []notice{{Severity: "NOTICE", Code: "00000", Message: "uid=999(postgres)"}},
+	}}
+	srv := newServer(q, nil)
+	rec := httptest.NewRecorder()
+	body := `{"q":"DO $$BEGIN RAISE NOTICE '%', pg_read_file('/etc/hostname'); END$$"}`
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/admin/sql", strings.NewReader(body)))
+
+	if q.lastSQL != "DO $$BEGIN RAISE NOTICE '%', pg_read_file('/etc/hostname'); END$$" {
+		t.Errorf("raw query was not forwarded verbatim: %q", q.lastSQL)
+	}
+	if !strings.Contains(rec.Body.String(), `"notices":[{"severity":"NOTICE","code":"00000","message":"uid=999(postgres)"}]`) {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
+
+// TestPgExecutorPool_TimeoutPerPool pins that statement_timeout rides
+// on the connection's startup parameters, one pool per timeout, and
+// never on the untimed pool migrations use. No postgres needed: pools
+// dial lazily.
+func TestPgExecutorPool_TimeoutPerPool(t *testing.T) {
+	p, err := newPgExecutor("host=127.0.0.1 port=1 user=postgres sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := statementContext(context.Background(), 5*time.Second)
+	defer cancel()
+	a, err := p.pool(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := p.pool(ctx); a != b {
+		t.Error("same timeout got a second pool")
+	}
+	if a == p.db {
+		t.Error("timed query shares the untimed pool")
+	}
+	if got, _ := p.pool(context.Background()); got != p.db {
+		t.Error("untimed query did not use the base pool")
+	}
+	if p.cfg.Runtime["statement_timeout"] != "" {
+		t.Errorf("base config carries statement_timeout %q", p.cfg.Runtime["statement_timeout"])
+	}
+}
+
+// TestStatementTimeouts_PerEndpoint pins that each endpoint hands the
+// executor its own statement_timeout, and a context that expires
+// shortly after it so a payload that resets the timeout still ends.
+func TestStatementTimeouts_PerEndpoint(t *testing.T) {
+	exec := &stubExecutor{rows: "[]"}
+	srv := newServer(exec, nil, withStatementTimeouts(2*time.Second, 20*time.Second))
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/products", nil))
+	if d := statementTimeout(exec.lastCtx); d != 2*time.Second {
+		t.Errorf("products statement_timeout = %s, want 2s", d)
+	}
+	if dl, ok := exec.lastCtx.Deadline(); !ok || time.Until(dl) > 2*time.Second+statementGrace {
+		t.Errorf("products ctx deadline = %v, %v", dl, ok)
+	}
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/admin/sql", strings.NewReader(`{"q":"SELECT pg_sleep(600)"}`)))
+	if d := statementTimeout(exec.lastCtx); d != 20*time.Second {
+		t.Errorf("admin statement_timeout = %s, want 20s", d)
+	}
+}
+
+// TestAdminSQL_ClientDisconnectCancelsQuery pins cancel-on-disconnect:
+// when the request context ends, the executor's context ends with it
+// (pgExecutor turns that into a server-side CancelRequest).
+func TestAdminSQL_ClientDisconnectCancelsQuery(t *testing.T) {
+	exec := &stubExecutor{block: true}
+	srv := newServer(exec, nil)
+
+	ctx, hangUp := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/sql", strings.NewReader(`{"q":"SELECT pg_sleep(600)"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		srv.ServeHTTP(rec, req)
+		close(done)
+	}()
+	hangUp()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("query still running after the client hung up")
+	}
+	if !strings.Contains(rec.Body.String(), "context canceled") {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// respClient is a minimal, zero-dep RESP2 client: multibulk commands
+// out, the five RESP2 reply types back, over a small pool of idle
+// connections. It covers what the cart and the service registry need
+// and nothing else — the backend's go.mod stays at lib/pq alone.
+type respClient struct {
+	addr     string
+	username string // empty: password-only AUTH as the default user
+	password string
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	idle []*respConn
+}
+
+type respConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+// redisReply is one decoded reply. Nil marks a null bulk or array.
+type redisReply struct {
+	Kind  byte // '+', '-', ':', '$', '*'
+	Str   string
+	Int   int64
+	Elems []redisReply
+	Nil   bool
+}
+
+// redisError is a '-' reply.
+type redisError struct{ msg string }
+
+func (e *redisError) Error() string { return "redis: " + e.msg }
+
+const respMaxIdle = 4
+
+func newRespClient(addr, username, password string, timeout time.Duration) *respClient {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &respClient{addr: addr, username: username, password: password, timeout: timeout}
+}
+
+// Do sends one command; an error reply comes back as *redisError.
+func (c *respClient) Do(ctx context.Context, args ...string) (redisReply, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return redisReply{}, err
+	}
+	if replies[0].Kind == '-' {
+		return replies[0], &redisError{replies[0].Str}
+	}
+	return replies[0], nil
+}
+
+// Pipeline writes every command in one go and reads one reply per
+// command; error replies stay in place.
+func (c *respClient) Pipeline(ctx context.Context, cmds ...[]string) ([]redisReply, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	for _, cmd := range cmds {
+		writeCommand(&b, cmd)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	replies := make([]redisReply, len(cmds))
+	for i := range replies {
+		if replies[i], err = readReply(conn.br); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	c.put(conn)
+	return replies, nil
+}
+
+func (c *respClient) get(ctx context.Context) (*respConn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	d := net.Dialer{Timeout: c.timeout}
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &respConn{Conn: nc, br: bufio.NewReader(nc)}
+	if c.password != "" {
+		_ = conn.SetDeadline(time.Now().Add(c.timeout))
+		var b strings.Builder
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		writeCommand(&b, auth)
+		_, err := io.WriteString(conn, b.String())
+		var r redisReply
+		if err == nil {
+			r, err = readReply(conn.br)
+		}
+		if err == nil && r.Kind == '-' {
+			err = &redisError{r.Str}
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("AUTH: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *respClient) put(conn *respConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= respMaxIdle {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+func writeCommand(b *strings.Builder, args []string) {
+	fmt.Fprintf(b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(b, "$%d\r\n%s\r\n", len(a), a)
+	}
+}
+
+// readReply decodes one RESP2 reply. Bulk strings are capped at 16 MiB
+// and arrays at 64k elements; a cart never comes close.
+func readReply(br *bufio.Reader) (redisReply, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return redisReply{}, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return redisReply{}, errors.New("redis: empty reply line")
+	}
+	r := redisReply{Kind: line[0]}
+	switch line[0] {
+	case '+', '-':
+		r.Str = line[1:]
+	case ':':
+		if r.Int, err = strconv.ParseInt(line[1:], 10, 64); err != nil {
+			return r, fmt.Errorf("redis: bad integer %q", line)
+		}
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > 16<<20 {
+			return r, fmt.Errorf("redis: bad bulk length %q", line)
+		}
+		if n < 0 {
+			r.Nil = true
+			return r, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return r, err
+		}
+		r.Str = string(buf[:n])
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > 1<<16 {
+			return r, fmt.Errorf("redis: bad array length %q", line)
+		}
+		if n < 0 {
+			r.Nil = true
+			return r, nil
+		}
+		r.Elems = make([]redisReply, n)
+		for i := range r.Elems {
+			if r.Elems[i], err = readReply(br); err != nil {
+				return r, err
+			}
+		}
+	default:
+		return r, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+	return r, nil
+}
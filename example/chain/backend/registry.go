@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// registryKey is the sorted set backend replicas announce themselves
+// in: member host:port, score the unix-ms time the entry expires.
+// chain-frontend's discovery mode (BACKEND_DISCOVERY=redis) reads the
+// members whose score is still in the future. Nothing authenticates an
+// entry, so anyone who can write to redis — /api/cache/eval included —
+// can add a member and receive frontend traffic.
+const registryKey = "chain:registry:backend"
+
+// registrar keeps one replica's registry entry alive.
+type registrar struct {
+	rd   *respClient
+	self string
+	ttl  time.Duration
+}
+
+// heartbeat (re)announces self for ttl and drops expired members.
+func (g *registrar) heartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	replies, err := g.rd.Pipeline(ctx,
+		[]string{"ZADD", registryKey, strconv.FormatInt(now.Add(g.ttl).UnixMilli(), 10), g.self},
+		[]string{"ZREMRANGEBYSCORE", registryKey, "-inf", strconv.FormatInt(now.UnixMilli(), 10)},
+	)
+	if err != nil {
+		return err
+	}
+	for _, r := range replies {
+		if r.Kind == '-' {
+			return &redisError{r.Str}
+		}
+	}
+	return nil
+}
+
+// run heartbeats every ttl/3 until ctx is done, so one lost beat
+// doesn't drop the replica.
+func (g *registrar) run(ctx context.Context) {
+	t := time.NewTicker(g.ttl / 3)
+	defer t.Stop()
+	for {
+		if err := g.heartbeat(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("WARN: registry heartbeat %s: %v", g.self, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// advertiseAddr is the host:port other pods reach this replica on:
+// ADVERTISE_ADDR if set, else POD_IP (downward API) or the hostname
+// with LISTEN_ADDR's port.
+func advertiseAddr(listen string) string {
+	if a := getenv("ADVERTISE_ADDR", ""); a != "" {
+		return a
+	}
+	_, port, err := net.SplitHostPort(listen)
+	if err != nil || port == "" {
+		port = "8080"
+	}
+	host := getenv("POD_IP", "")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return net.JoinHostPort(host, port)
+}
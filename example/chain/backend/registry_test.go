@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRegistrarHeartbeat pins the wire shape chain-frontend's
+// discovery mode depends on: ZADD chain:registry:backend <expiry-ms>
+// <host:port>, then a sweep of expired members.
+func TestRegistrarHeartbeat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		var lines []string
+		for len(lines) < 2 {
+			hdr, _ := br.ReadString('\n')
+			n, _ := strconv.Atoi(strings.TrimSpace(hdr[1:]))
+			var args []string
+			for i := 0; i < n; i++ {
+				_, _ = br.ReadString('\n')
+				a, _ := br.ReadString('\n')
+				args = append(args, strings.TrimSpace(a))
+			}
+			lines = append(lines, strings.Join(args, " "))
+		}
+		_, _ = io.WriteString(conn, ":1\r\n:0\r\n")
+		got <- lines
+	}()
+
+	g := &registrar{rd: newRespClient(ln.Addr().String(), "", "", time.Second), self: "10.0.0.7:8080", ttl: 15 * time.Second}
+	before := time.Now().Add(15 * time.Second).UnixMilli()
+	if err := g.heartbeat(context.Background()); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	cmds := <-got
+	f := strings.Fields(cmds[0])
+	if len(f) != 4 || f[0] != "ZADD" || f[1] != registryKey || f[3] != "10.0.0.7:8080" {
+		t.Fatalf("first command = %q", cmds[0])
+	}
+	if exp, _ := strconv.ParseInt(f[2], 10, 64); exp < before {
+		t.Errorf("expiry %d is earlier than now+ttl", exp)
+	}
+	if !strings.HasPrefix(cmds[1], "ZREMRANGEBYSCORE "+registryKey+" -inf ") {
+		t.Errorf("second command = %q", cmds[1])
+	}
+}
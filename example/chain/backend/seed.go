@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Seed sizes. Small enough to insert in well under a second, large
+// enough that a dumped table looks like a shop and not a fixture.
+const (
+	seedProducts  = 40
+	seedCustomers = 200
+	seedOrders    = 600
+)
+
+// seedVersion sorts the seeder after every schema migration, so on a
+// fresh database it always sees the final shape of the tables.
+const seedVersion = 1000
+
+// seedEpoch anchors order and key timestamps so every run produces the
+// same rows, byte for byte.
+var seedEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type seedProduct struct {
+	Name       string
+	PriceCents int
+	Stock      int
+}
+
+// seedCustomer is synthetic PII: SSNs use the never-issued 9xx area,
+// phones the 555-01xx fictional block and emails example.com.
+type seedCustomer struct {
+	Name, Email, Phone, Address, SSN, CardLast4 string
+}
+
+type seedOrder struct {
+	CustomerID, ProductID, Qty, TotalCents int
+	CreatedAt                              time.Time
+}
+
+type seedAPIKey struct {
+	CustomerID int
+	Key        string
+	Scopes     string
+	CreatedAt  time.Time
+}
+
+type seedSet struct {
+	Products  []seedProduct
+	Customers []seedCustomer
+	Orders    []seedOrder
+	APIKeys   []seedAPIKey
+}
+
+var (
+	seedAdjectives = []string{"Red", "Steel", "Quiet", "Rapid", "Stone", "Amber", "Polar", "Cedar"}
+	seedNouns      = []string{"Widget", "Kettle", "Lamp", "Backpack", "Mug", "Stool", "Router", "Notebook", "Clock", "Blender"}
+	seedFirst      = []string{"Ada", "Ben", "Chloe", "Dev", "Elena", "Femi", "Grace", "Hiro", "Ines", "Jonas", "Kira", "Liam", "Mei", "Noor", "Omar", "Priya"}
+	seedLast       = []string{"Alvarez", "Brown", "Chen", "Dubois", "Eriksen", "Fischer", "Garcia", "Haddad", "Ito", "Jensen", "Kowalski", "Lopez"}
+	seedStreets    = []string{"Main St", "Oak Ave", "Harbor Rd", "Elm St", "Mill Ln", "Park Blvd"}
+	seedCities     = []string{"Springfield, IL", "Riverton, WY", "Fairview, OR", "Georgetown, TX", "Salem, MA"}
+	seedScopes     = []string{"read", "read,write", "read,write,admin"}
+)
+
+// seedData generates the demo rows from a fixed PCG seed. Order and key
+// references are 1-based positions in the Products and Customers
+// slices; seedMigration maps them to the ids postgres assigns.
+func seedData() seedSet {
+	rng := rand.New(rand.NewPCG(0x626f62, 0x636861696e))
+	pick := func(s []string) string { return s[rng.IntN(len(s))] }
+	var s seedSet
+
+	for i := range seedProducts {
+		s.Products = append(s.Products, seedProduct{
+			Name:       fmt.Sprintf("%s %s %d", pick(seedAdjectives), pick(seedNouns), i+1),
+			PriceCents: 199 + rng.IntN(20000),
+			Stock:      rng.IntN(500),
+		})
+	}
+	for i := range seedCustomers {
+		first, last := pick(seedFirst), pick(seedLast)
+		s.Customers = append(s.Customers, seedCustomer{
+			Name:      first + " " + last,
+			Email:     fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1),
+			Phone:     fmt.Sprintf("+1-%03d-555-01%02d", 200+rng.IntN(800), rng.IntN(100)),
+			Address:   fmt.Sprintf("%d %s, %s", 1+rng.IntN(9999), pick(seedStreets), pick(seedCities)),
+			SSN:       fmt.Sprintf("9%02d-%02d-%04d", rng.IntN(100), 1+rng.IntN(99), 1+rng.IntN(9999)),
+			CardLast4: fmt.Sprintf("%04d", rng.IntN(10000)),
+		})
+	}
+	for range seedOrders {
+		p := rng.IntN(seedProducts)
+		qty := 1 + rng.IntN(5)
+		s.Orders = append(s.Orders, seedOrder{
+			CustomerID: 1 + rng.IntN(seedCustomers),
+			ProductID:  p + 1,
+			Qty:        qty,
+			TotalCents: qty * s.Products[p].PriceCents,
+			CreatedAt:  seedEpoch.Add(time.Duration(rng.IntN(365*24*60)) * time.Minute),
+		})
+	}
+	// Every fourth customer has an integration key.
+	for id := 1; id <= seedCustomers; id += 4 {
+		raw := make([]byte, 16)
+		for i := range raw {
+			raw[i] = byte(rng.Uint32())
+		}
+		s.APIKeys = append(s.APIKeys, seedAPIKey{
+			CustomerID: id,
+			Key:        "sk_live_" + hex.EncodeToString(raw),
+			Scopes:     pick(seedScopes),
+			CreatedAt:  seedEpoch.Add(time.Duration(id) * time.Hour),
+		})
+	}
+	return s
+}
+
+// seedMigration loads seedData inside the migration's transaction. It
+// is a versioned migration like the SQL files, so it runs exactly once
+// per database. Foreign keys go through the ids postgres hands back, so
+// rows seeded out-of-band before the first migration don't skew them.
+func seedMigration(version int) migration {
+	return migration{version: version, name: "seed", fn: func(ctx context.Context, tx *sql.Tx) error {
+		s := seedData()
+		// insert runs query once per row and returns the generated ids.
+		insert := func(query string, rows int, args func(i int) []any) ([]int, error) {
+			stmt, err := tx.PrepareContext(ctx, query+" RETURNING id")
+			if err != nil {
+				return nil, err
+			}
+			defer stmt.Close()
+			ids := make([]int, rows)
+			for i := range ids {
+				if err := stmt.QueryRowContext(ctx, args(i)...).Scan(&ids[i]); err != nil {
+					return nil, err
+				}
+			}
+			return ids, nil
+		}
+		productIDs, err := insert("INSERT INTO products (name, price_cents, stock) VALUES ($1, $2, $3)", len(s.Products), func(i int) []any {
+			p := s.Products[i]
+			return []any{p.Name, p.PriceCents, p.Stock}
+		})
+		if err != nil {
+			return fmt.Errorf("products: %w", err)
+		}
+		customerIDs, err := insert("INSERT INTO customers (name, email, phone, address, ssn, card_last4) VALUES ($1, $2, $3, $4, $5, $6)", len(s.Customers), func(i int) []any {
+			c := s.Customers[i]
+			return []any{c.Name, c.Email, c.Phone, c.Address, c.SSN, c.CardLast4}
+		})
+		if err != nil {
+			return fmt.Errorf("customers: %w", err)
+		}
+		if _, err := insert("INSERT INTO orders (customer_id, product_id, qty, total_cents, created_at) VALUES ($1, $2, $3, $4, $5)", len(s.Orders), func(i int) []any {
+			o := s.Orders[i]
+			return []any{customerIDs[o.CustomerID-1], productIDs[o.ProductID-1], o.Qty, o.TotalCents, o.CreatedAt}
+		}); err != nil {
+			return fmt.Errorf("orders: %w", err)
+		}
+		if _, err := insert("INSERT INTO api_keys (customer_id, key, scopes, created_at) VALUES ($1, $2, $3, $4)", len(s.APIKeys), func(i int) []any {
+			k := s.APIKeys[i]
+			return []any{customerIDs[k.CustomerID-1], k.Key, k.Scopes, k.CreatedAt}
+		}); err != nil {
+			return fmt.Errorf("api_keys: %w", err)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// redisCredentials is the ACL identity the frontend presents to
+// redis. Each field may come inline (env) or from a file — the *File
+// variant wins, and is re-read on every dial so a rotated Secret mount
+// takes effect on the next fresh connection.
+//
+// The file path is deliberately the usual one (a Secret volume): in
+// the hardened chain the attacker has to find and read it — or lift
+// REDIS_PASSWORD out of /proc/1/environ — before EVAL works at all.
+type redisCredentials struct {
+	Username, UsernameFile string
+	Password, PasswordFile string
+}
+
+func (c redisCredentials) resolve() (user, pass string, err error) {
+	if user, err = readSecret(c.Username, c.UsernameFile); err != nil {
+		return "", "", err
+	}
+	if pass, err = readSecret(c.Password, c.PasswordFile); err != nil {
+		return "", "", err
+	}
+	return user, pass, nil
+}
+
+// readSecret returns the file's contents (trailing newline trimmed,
+// as `kubectl create secret --from-file` keeps it) or the inline value.
+func readSecret(inline, file string) (string, error) {
+	if file == "" {
+		return inline, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// redisTLSOptions mirrors the REDIS_TLS_* env vars.
+type redisTLSOptions struct {
+	CAFile, CertFile, KeyFile string
+	ServerName                string
+	InsecureSkipVerify        bool
+}
+
+// tlsConfig builds the client TLS config: a private CA bundle (else
+// the system pool), an optional client certificate for mutual TLS,
+// and an SNI override for when REDIS_ADDR is an IP or a Service name
+// that differs from the certificate's.
+func (o redisTLSOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify, // opt-in, for self-signed demo certs
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca: no certificates in %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client cert: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRespRedis_AuthFromSecretFile pins the hardened-chain handshake:
+// the ACL password is read from the mounted Secret file and sent as
+// AUTH <user> <pass> before the first real command.
+func TestRespRedis_AuthFromSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	passFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	addr, cmds := fakeRedis(t, []string{"+OK\r\n", ":1\r\n"})
+	rd := newRespRedis(addr, redisOptions{
+		Auth: redisCredentials{Username: "chain", PasswordFile: passFile},
+	})
+	if _, err := rd.Do(context.Background(), "INCR", "chain:counter"); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	rd.Close()
+	seen := <-cmds
+	if len(seen) != 2 || strings.Join(seen[0], " ") != "AUTH chain s3cret" {
+		t.Errorf("server saw %q, want AUTH chain s3cret first", seen)
+	}
+}
+
+// TestRespRedis_HelloCarriesAuth pins that RESP3 mode folds the
+// credentials into HELLO instead of sending a separate AUTH.
+func TestRespRedis_HelloCarriesAuth(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"%1\r\n+proto\r\n:3\r\n", "+PONG\r\n"})
+	rd := newRespRedis(addr, redisOptions{Proto: 3, Auth: redisCredentials{Password: "pw"}})
+	if _, err := rd.Do(context.Background(), "PING"); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	rd.Close()
+	if seen := <-cmds; strings.Join(seen[0], " ") != "HELLO 3 AUTH default pw" {
+		t.Errorf("handshake = %q, want HELLO 3 AUTH default pw", seen[0])
+	}
+}
+
+func TestRespRedis_WrongPassSurfaces(t *testing.T) {
+	addr, _ := fakeRedis(t, []string{"-WRONGPASS invalid username-password pair\r\n"})
+	rd := newRespRedis(addr, redisOptions{Auth: redisCredentials{Password: "nope"}})
+	_, err := rd.Do(context.Background(), "PING")
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("err = %v, want WRONGPASS", err)
+	}
+}
+
+// TestRespRedis_TLSWithPrivateCA pins the TLS leg: a server cert issued
+// for the Service name verifies against REDIS_TLS_CA_FILE when the SNI
+// override names it, even though REDIS_ADDR is an IP.
+func TestRespRedis_TLSWithPrivateCA(t *testing.T) {
+	caFile, serverCert := testCertificate(t, "chain-redis.chain.svc")
+	listen := func() string {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		addr, _ := serveFakeRedis(t, ln, []string{"+PONG\r\n"})
+		return addr
+	}
+
+	cfg, err := redisTLSOptions{CAFile: caFile, ServerName: "chain-redis.chain.svc"}.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	rd := newRespRedis(listen(), redisOptions{TLS: cfg})
+	v, err := rd.Do(context.Background(), "PING")
+	if err != nil || v.Str != "PONG" {
+		t.Fatalf("Do over TLS = %+v, %v; want PONG", v, err)
+	}
+
+	cfg.ServerName = "somewhere-else"
+	rd = newRespRedis(listen(), redisOptions{TLS: cfg})
+	if _, err := rd.Do(context.Background(), "PING"); err == nil {
+		t.Error("Do with mismatched SNI succeeded, want verification failure")
+	}
+}
+
+// testCertificate writes a self-signed CA-capable certificate for host
+// to a temp file and returns the path plus the key pair for a server.
+func testCertificate(t *testing.T, host string) (string, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, pair
+}
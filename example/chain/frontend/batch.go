@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxBatch bounds one /api/cache/batch request. Carts and counters
+// send a handful of commands; the cap only stops a single request from
+// monopolising a pooled connection.
+const maxBatch = 1000
+
+// registerBatchRoutes wires POST /api/cache/batch:
+//
+//	{"commands": [["HSET","cart:42","sku-1","2"], ["EXPIRE","cart:42","3600"]],
+//	 "mode": "pipeline" | "multi", "watch": ["cart:42"]}
+//
+// "pipeline" (the default) sends every command in one write and
+// answers {"replies": [...]}, one per command, error replies in place.
+// "multi" wraps them as WATCH <watch...> / MULTI / … / EXEC on one
+// connection and answers with EXEC's replies; when a watched key
+// changed before EXEC the transaction is dropped and the answer is
+// {"aborted": true}. Like every other cache endpoint, the commands are
+// forwarded as given — except, in either mode, connection-state
+// commands (MULTI, WATCH, SELECT, SUBSCRIBE, CLIENT, …): the batch
+// runs on a pooled connection, and they would leave it broken for
+// whoever borrows it next.
+func registerBatchRoutes(mux *http.ServeMux, rd redisClient) {
+	mux.HandleFunc("/api/cache/batch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Commands [][]string `json:"commands"`
+			Mode     string     `json:"mode,omitempty"`
+			Watch    []string   `json:"watch,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Commands) == 0 || len(req.Commands) > maxBatch {
+			http.Error(w, "commands must hold 1 to "+strconv.Itoa(maxBatch)+" commands", http.StatusBadRequest)
+			return
+		}
+		for i, c := range req.Commands {
+			if len(c) == 0 {
+				http.Error(w, "commands["+strconv.Itoa(i)+"] is empty", http.StatusBadRequest)
+				return
+			}
+			if changesConnState(c) {
+				http.Error(w, "commands["+strconv.Itoa(i)+"]: "+c[0]+" changes connection state; use mode multi and watch for transactions", http.StatusBadRequest)
+				return
+			}
+		}
+
+		switch strings.ToLower(req.Mode) {
+		case "", "pipeline":
+			if len(req.Watch) > 0 {
+				http.Error(w, "watch needs mode multi", http.StatusBadRequest)
+				return
+			}
+			replies, err := rd.Pipeline(r.Context(), req.Commands...)
+			writeBatch(w, r, replies, err)
+		case "multi":
+			var cmds [][]string
+			if len(req.Watch) > 0 {
+				cmds = append(cmds, append([]string{"WATCH"}, req.Watch...))
+			}
+			cmds = append(cmds, []string{"MULTI"})
+			cmds = append(cmds, req.Commands...)
+			cmds = append(cmds, []string{"EXEC"})
+			replies, err := rd.Pipeline(r.Context(), cmds...)
+			if err != nil {
+				writeBatch(w, r, nil, err)
+				return
+			}
+			// A command redis refused to queue makes EXEC fail with
+			// EXECABORT; the refusal itself says why.
+			exec := replies[len(replies)-1]
+			if exec.Kind == respError {
+				for _, q := range replies[:len(replies)-1] {
+					if q.Kind == respError {
+						exec = q
+						break
+					}
+				}
+				writeBatch(w, r, nil, exec.Err())
+				return
+			}
+			if exec.Kind == respNil {
+				writeJSON(w, http.StatusOK, map[string]bool{"aborted": true})
+				return
+			}
+			writeBatch(w, r, exec.Elems, nil)
+		default:
+			http.Error(w, "mode must be pipeline or multi", http.StatusBadRequest)
+		}
+	})
+}
+
+// writeBatch is writeReply for several replies.
+func writeBatch(w http.ResponseWriter, r *http.Request, replies []respValue, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]string{"error": errorText(r, err)})
+		return
+	}
+	if replies == nil {
+		replies = []respValue{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]respValue{"replies": replies})
+}
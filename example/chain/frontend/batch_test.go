@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postBatch(t *testing.T, rd redisClient, body string) map[string]json.RawMessage {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	newServer(nil, rd).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %q", rec.Code, rec.Body.String())
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body %q: %v", rec.Body.String(), err)
+	}
+	return got
+}
+
+// TestCacheBatch_Pipeline pins the default mode: every command goes
+// out in one pipeline and the replies come back in order, a failing
+// command's error in its slot rather than failing the batch.
+func TestCacheBatch_Pipeline(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"+OK\r\n", "-WRONGTYPE Operation against a key\r\n", ":3\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	got := postBatch(t, rd, `{"commands":[["SET","a","1"],["HGET","a","f"],["INCR","n"]]}`)
+	rd.Close()
+
+	if string(got["replies"]) != `["OK",{"error":"WRONGTYPE Operation against a key"},3]` {
+		t.Errorf("replies = %s", got["replies"])
+	}
+	if seen := <-cmds; len(seen) != 3 {
+		t.Errorf("server saw %q, want the 3 commands", seen)
+	}
+}
+
+// TestCacheBatch_MultiWatch pins the transaction shape on the wire —
+// WATCH, MULTI, the commands, EXEC on one connection — and that the
+// caller gets EXEC's replies, not the QUEUED acknowledgements.
+func TestCacheBatch_MultiWatch(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"+OK\r\n", "+OK\r\n", "+QUEUED\r\n", "+QUEUED\r\n", "*2\r\n:2\r\n:1\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	got := postBatch(t, rd, `{"mode":"multi","watch":["cart:42"],"commands":[["HINCRBY","cart:42","sku-1","1"],["EXPIRE","cart:42","3600"]]}`)
+	rd.Close()
+
+	if string(got["replies"]) != `[2,1]` {
+		t.Errorf("replies = %s, want EXEC's [2,1]", got["replies"])
+	}
+	var verbs []string
+	for _, c := range <-cmds {
+		verbs = append(verbs, c[0])
+	}
+	if strings.Join(verbs, " ") != "WATCH MULTI HINCRBY EXPIRE EXEC" {
+		t.Errorf("wire order = %q", verbs)
+	}
+}
+
+func TestCacheBatch_MultiAbortedByWatch(t *testing.T) {
+	addr, _ := fakeRedis(t, []string{"+OK\r\n", "+OK\r\n", "+QUEUED\r\n", "*-1\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	got := postBatch(t, rd, `{"mode":"multi","watch":["k"],"commands":[["INCR","k"]]}`)
+	rd.Close()
+	if string(got["aborted"]) != "true" {
+		t.Errorf("body = %v, want aborted", got)
+	}
+}
+
+func TestCacheBatch_RejectsBadRequests(t *testing.T) {
+	srv := newServer(nil, &stubRedis{})
+	for _, body := range []string{
+		`{"commands":[]}`,
+		`{"commands":[[]]}`,
+		`{"commands":[["GET","k"]],"mode":"lua"}`,
+		`{"commands":[["GET","k"]],"watch":["k"]}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/batch", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+// TestCacheBatch_RefusesConnStateCommands pins that no batch can leave
+// its pooled connection mid-transaction, subscribed or silenced: such
+// batches are refused unsent in both modes, and the next Do on the
+// pool gets its own reply.
+func TestCacheBatch_RefusesConnStateCommands(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{":1\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	srv := newServer(nil, rd)
+	for _, body := range []string{
+		`{"commands":[["MULTI"],["SET","a","1"]]}`,
+		`{"commands":[["SUBSCRIBE","a","b"]]}`,
+		`{"commands":[["monitor"]]}`,
+		`{"commands":[["CLIENT","REPLY","OFF"],["GET","a"]]}`,
+		`{"mode":"multi","commands":[["SET","a","1"],["EXEC"]]}`,
+		`{"mode":"multi","commands":[["SELECT","1"]]}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/batch", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if v, err := rd.Do(context.Background(), "EVAL", "return 1", "0"); err != nil || v.Kind != respInt || v.Int != 1 {
+		t.Errorf("next Do = %+v, %v; want 1", v, err)
+	}
+	rd.Close()
+	if seen := <-cmds; len(seen) != 1 || seen[0][0] != "EVAL" {
+		t.Errorf("redis saw %q, want only the EVAL", seen)
+	}
+}
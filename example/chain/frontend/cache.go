@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// cacheStore is the key/value surface the product cache and the
+// counter endpoint need, so CACHE_BACKEND can put them on redis
+// (redisStore) or memcached (memcacheClient) without touching the
+// handlers.
+type cacheStore interface {
+	// Get returns the value and whether the key exists.
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set stores value for ttl.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Delete removes key; a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Incr adds delta to the counter at key, creating it at 0 first.
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+}
+
+// redisStore is cacheStore over GET / SETEX / DEL / INCRBY.
+type redisStore struct{ rd redisClient }
+
+func (s redisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	v, err := s.rd.Do(ctx, "GET", key)
+	if err != nil || v.Kind == respNil {
+		return "", false, err
+	}
+	return v.String(), true, nil
+}
+
+func (s redisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	secs := strconv.FormatInt(max(1, int64(ttl/time.Second)), 10)
+	_, err := s.rd.Do(ctx, "SETEX", key, secs, value)
+	return err
+}
+
+func (s redisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.rd.Do(ctx, "DEL", key)
+	return err
+}
+
+func (s redisStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	v, err := s.rd.Do(ctx, "INCRBY", key, strconv.FormatInt(delta, 10))
+	return v.Int, err
+}
+
+// registerCounterRoutes wires POST /api/cache/counter/{name}[?by=N],
+// the protocol-neutral counter: chain:counter:<name> += N (default 1)
+// on whichever cacheStore is configured, answering {"key","value"}.
+func registerCounterRoutes(mux *http.ServeMux, store cacheStore) {
+	mux.HandleFunc("POST /api/cache/counter/{name}", func(w http.ResponseWriter, r *http.Request) {
+		by := int64(1)
+		if s := r.URL.Query().Get("by"); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				http.Error(w, "by must be an integer", http.StatusBadRequest)
+				return
+			}
+			by = n
+		}
+		key := "chain:counter:" + r.PathValue("name")
+		n, err := store.Incr(r.Context(), key, by)
+		if err != nil {
+			writeJSON(w, http.StatusOK, map[string]string{"error": errorText(r, err)})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"key": key, "value": n})
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxCartBody caps a proxied cart request; a line item is a few bytes.
+const maxCartBody = 64 << 10
+
+// registerCartRoutes proxies the shopper's cart to chain-backend, which
+// keeps it in redis hashes (cart:<user>):
+//
+//	GET    /api/cart/{user}
+//	POST   /api/cart/{user}/items        {"sku","qty"}
+//	DELETE /api/cart/{user}/items/{sku}
+//	POST   /api/cart/{user}/checkout
+//
+// Method, path and body go through as-is; the backend validates them.
+func registerCartRoutes(mux *http.ServeMux, be backendClient) {
+	mux.HandleFunc("/api/cart/", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := be.(backendSender)
+		if !ok {
+			http.Error(w, "cart proxy not configured", http.StatusNotImplemented)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCartBody))
+		if err != nil {
+			http.Error(w, "read body: "+err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		status, resp, err := s.Send(r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type"), body)
+		if errors.Is(err, errBackendReadOnly) {
+			http.Error(w, "cart proxy not configured", http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			http.Error(w, "backend unreachable: "+errorText(r, err), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, resp)
+	})
+}
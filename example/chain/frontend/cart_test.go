@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCartRoutes_ProxyToBackend pins that the cart reaches
+// chain-backend through the same wrapped client /api/products uses,
+// with method, path and body intact.
+func TestCartRoutes_ProxyToBackend(t *testing.T) {
+	var got string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("Content-Type") + " " + string(b)
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"sku":"sku-1","qty":2}`)
+	}))
+	defer upstream.Close()
+	be := newCachedBackend(&httpBackend{base: upstream.URL, client: upstream.Client()}, redisStore{&kvRedis{}}, ttlOf(time.Minute))
+	srv := newServer(be, &stubRedis{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/cart/42/items", strings.NewReader(`{"sku":"sku-1","qty":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"sku":"sku-1","qty":2}` {
+		t.Errorf("response = %d %s", rec.Code, rec.Body.String())
+	}
+	if got != `POST /api/cart/42/items application/json {"sku":"sku-1","qty":2}` {
+		t.Errorf("backend saw %q", got)
+	}
+}
+
+func TestCartRoutes_ReadOnlyBackend(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newServer(&stubBackend{}, &stubRedis{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/cart/42", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", rec.Code)
+	}
+}
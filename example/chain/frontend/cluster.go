@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// clusterRedis is the REDIS_MODE=cluster client. It discovers the
+// slot → master map with CLUSTER SHARDS, routes each command to the
+// master owning its first key's slot, and follows MOVED / ASK
+// redirects. Every master gets its own pooled respRedis, so one EVAL
+// per shard shows up as a distinct frontend → redis-N network edge —
+// exactly the shard-dependent egress NetworkNeighborhood has to learn.
+type clusterRedis struct {
+	seeds []string
+	opts  redisOptions
+
+	mu     sync.RWMutex
+	slots  []slotRange // sorted by start
+	stale  bool        // a MOVED was seen; re-learn before the next route
+	nodes  map[string]*respRedis
+	random int // round-robin cursor for keyless commands
+}
+
+// slotRange maps [start, end] to the master serving it.
+type slotRange struct {
+	start, end int
+	addr       string
+}
+
+// clusterMaxRedirects bounds MOVED/ASK hops per command; a healthy
+// cluster needs at most two (one MOVED during resharding, one ASK).
+const clusterMaxRedirects = 5
+
+func newClusterRedis(seeds []string, opts redisOptions) *clusterRedis {
+	return &clusterRedis{seeds: seeds, opts: opts, nodes: map[string]*respRedis{}}
+}
+
+// Do routes one command by key slot and follows redirects.
+func (c *clusterRedis) Do(ctx context.Context, args ...string) (respValue, error) {
+	if err := c.ensureSlots(ctx); err != nil {
+		return respValue{}, err
+	}
+	if len(args) > 0 && clusterBroadcast[strings.ToUpper(args[0])] {
+		return c.broadcast(ctx, args)
+	}
+	addr := c.route(args)
+	asking := false
+	for hop := 0; ; hop++ {
+		node := c.node(addr)
+		var (
+			v   respValue
+			err error
+		)
+		if asking {
+			var replies []respValue
+			replies, err = node.Pipeline(ctx, []string{"ASKING"}, args)
+			if err == nil {
+				v, err = replies[1], replies[1].Err()
+			}
+		} else {
+			v, err = node.Do(ctx, args...)
+		}
+		redirect, target, ok := parseRedirect(err)
+		if !ok || hop == clusterMaxRedirects {
+			return v, err
+		}
+		if redirect == "MOVED" {
+			// Slot ownership changed for good: follow the hint now and
+			// re-learn the full map before the next command.
+			c.mu.Lock()
+			c.stale = true
+			c.mu.Unlock()
+		}
+		addr, asking = target, redirect == "ASK"
+	}
+}
+
+// Pipeline sends every command to the master owning the first keyed
+// command's slot. Cross-slot batches are the caller's problem, as
+// with any cluster client. A MOVED reply re-learns the slot map and
+// re-sends the whole pipeline to the new owner: re-issuing just the
+// redirected commands would land queued commands on a connection that
+// never saw MULTI. An ASK (slot mid-migration) fails the pipeline.
+func (c *clusterRedis) Pipeline(ctx context.Context, cmds ...[]string) ([]respValue, error) {
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	if err := c.ensureSlots(ctx); err != nil {
+		return nil, err
+	}
+	addr := ""
+	for _, cmd := range cmds {
+		if _, ok := commandKey(cmd); ok {
+			addr = c.route(cmd)
+			break
+		}
+	}
+	if addr == "" {
+		addr = c.route(cmds[0])
+	}
+	for hop := 0; ; hop++ {
+		replies, err := c.node(addr).Pipeline(ctx, cmds...)
+		if err != nil {
+			return nil, err
+		}
+		redirect, target, rerr := pipelineRedirect(replies)
+		if rerr == nil {
+			return replies, nil
+		}
+		if redirect == "ASK" || hop == clusterMaxRedirects {
+			return nil, fmt.Errorf("cluster pipeline: %w", rerr)
+		}
+		c.mu.Lock()
+		c.stale = true
+		c.mu.Unlock()
+		_ = c.ensureSlots(ctx)
+		addr = target
+	}
+}
+
+// pipelineRedirect returns the first MOVED/ASK among replies.
+func pipelineRedirect(replies []respValue) (kind, addr string, err error) {
+	for _, v := range replies {
+		if kind, addr, ok := parseRedirect(v.Err()); ok {
+			return kind, addr, v.Err()
+		}
+	}
+	return "", "", nil
+}
+
+// Subscribe listens on one master picked round-robin. PUBLISH is
+// cluster-wide, so channel and pattern subscriptions see every
+// message; keyspace notifications are node-local and only cover the
+// keys that master owns.
+func (c *clusterRedis) Subscribe(ctx context.Context, channels, patterns []string, fn func(pubsubMessage)) error {
+	if err := c.ensureSlots(ctx); err != nil {
+		return err
+	}
+	return c.node(c.route(nil)).Subscribe(ctx, channels, patterns, fn)
+}
+
+// Stats sums the per-master pools.
+func (c *clusterRedis) Stats() poolStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return sumStats(c.nodes)
+}
+
+// Close closes every per-master pool.
+func (c *clusterRedis) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, n := range c.nodes {
+		_ = n.Close()
+	}
+	return nil
+}
+
+// clusterBroadcast lists keyless commands that must reach every master:
+// loaded scripts and function libraries live per node.
+var clusterBroadcast = map[string]bool{"SCRIPT": true, "FUNCTION": true}
+
+func (c *clusterRedis) broadcast(ctx context.Context, args []string) (respValue, error) {
+	c.mu.RLock()
+	masters := c.masters()
+	c.mu.RUnlock()
+	var (
+		first    respValue
+		firstErr error
+	)
+	for i, addr := range masters {
+		v, err := c.node(addr).Do(ctx, args...)
+		if i == 0 {
+			first, firstErr = v, err
+		} else if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", addr, err)
+		}
+	}
+	return first, firstErr
+}
+
+// route picks the master for args: by key slot when the command has a
+// key, else round-robin across masters.
+func (c *clusterRedis) route(args []string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := commandKey(args); ok {
+		slot := keySlot(key)
+		i := sort.Search(len(c.slots), func(i int) bool { return c.slots[i].end >= slot })
+		if i < len(c.slots) && c.slots[i].start <= slot {
+			return c.slots[i].addr
+		}
+	}
+	masters := c.masters()
+	if len(masters) == 0 {
+		return c.seeds[0]
+	}
+	c.random++
+	return masters[c.random%len(masters)]
+}
+
+// masters lists distinct master addresses; caller holds c.mu.
+func (c *clusterRedis) masters() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range c.slots {
+		if !seen[s.addr] {
+			seen[s.addr] = true
+			out = append(out, s.addr)
+		}
+	}
+	return out
+}
+
+// node returns (creating on first use) the pool for addr.
+func (c *clusterRedis) node(addr string) *respRedis {
+	c.mu.RLock()
+	n := c.nodes[addr]
+	c.mu.RUnlock()
+	if n != nil {
+		return n
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n = c.nodes[addr]; n == nil {
+		n = newRespRedis(addr, c.opts)
+		c.nodes[addr] = n
+	}
+	return n
+}
+
+// ensureSlots loads the slot map on first use and after a MOVED. A
+// failed re-learn keeps the old map — redirects still get commands
+// where they need to go.
+func (c *clusterRedis) ensureSlots(ctx context.Context) error {
+	c.mu.RLock()
+	have, stale := len(c.slots) > 0, c.stale
+	c.mu.RUnlock()
+	if have && !stale {
+		return nil
+	}
+	if err := c.refresh(ctx); err != nil && !have {
+		return err
+	}
+	return nil
+}
+
+// refresh asks each seed (then each known master) for CLUSTER SHARDS
+// until one answers.
+func (c *clusterRedis) refresh(ctx context.Context) error {
+	c.mu.RLock()
+	candidates := append(append([]string(nil), c.seeds...), c.masters()...)
+	c.mu.RUnlock()
+	var lastErr error
+	for _, addr := range candidates {
+		v, err := c.node(addr).Do(ctx, "CLUSTER", "SHARDS")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		slots, err := parseClusterShards(v, c.opts.TLS != nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.mu.Lock()
+		c.slots, c.stale = slots, false
+		c.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("cluster shards: %w", lastErr)
+}
+
+// parseClusterShards turns a CLUSTER SHARDS reply into sorted slot
+// ranges owned by online masters. Each shard is a map (RESP3) or a
+// flat key/value array (RESP2) — both land as alternating Elems.
+func parseClusterShards(v respValue, useTLS bool) ([]slotRange, error) {
+	var out []slotRange
+	for _, shard := range v.Elems {
+		fields := respFields(shard)
+		var master string
+		for _, n := range fields["nodes"].Elems {
+			nf := respFields(n)
+			if nf["role"].String() != "master" || (nf["health"].Kind != respNil && nf["health"].String() != "online") {
+				continue
+			}
+			host := nf["endpoint"].String()
+			if host == "" || host == "?" {
+				host = nf["ip"].String()
+			}
+			port := nf["port"]
+			if useTLS && nf["tls-port"].Kind != respNil {
+				port = nf["tls-port"]
+			}
+			master = net.JoinHostPort(host, port.String())
+		}
+		if master == "" {
+			continue
+		}
+		ranges := fields["slots"].Elems
+		for i := 0; i+1 < len(ranges); i += 2 {
+			start, err1 := strconv.Atoi(ranges[i].String())
+			end, err2 := strconv.Atoi(ranges[i+1].String())
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("bad slot range %v", ranges[i:i+2])
+			}
+			out = append(out, slotRange{start: start, end: end, addr: master})
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no slots served by an online master")
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].start < out[j].start })
+	return out, nil
+}
+
+// respFields indexes a map / flat key-value array by key.
+func respFields(v respValue) map[string]respValue {
+	m := make(map[string]respValue, len(v.Elems)/2)
+	for i := 0; i+1 < len(v.Elems); i += 2 {
+		m[v.Elems[i].String()] = v.Elems[i+1]
+	}
+	return m
+}
+
+// parseRedirect recognises "MOVED <slot> <addr>" / "ASK <slot> <addr>".
+func parseRedirect(err error) (kind, addr string, ok bool) {
+	var re *redisError
+	if !errors.As(err, &re) {
+		return "", "", false
+	}
+	f := strings.Fields(re.msg)
+	if len(f) != 3 || (f[0] != "MOVED" && f[0] != "ASK") {
+		return "", "", false
+	}
+	return f[0], f[2], true
+}
+
+// commandKey returns the first key of args. Script and function calls
+// carry numkeys at args[2]; keyless admin commands report none; every
+// other command is assumed to take its key first, which holds for the
+// GET/SET/INCR/H*/Z* family the frontend sends.
+func commandKey(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "", false
+	}
+	switch strings.ToUpper(args[0]) {
+	case "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO":
+		if len(args) < 3 {
+			return "", false
+		}
+		if n, err := strconv.Atoi(args[2]); err == nil && n > 0 && len(args) > 3 {
+			return args[3], true
+		}
+		return "", false
+	case "PING", "ECHO", "INFO", "SCRIPT", "FUNCTION", "CLUSTER", "CONFIG",
+		"CLIENT", "HELLO", "AUTH", "SELECT", "PUBLISH", "SUBSCRIBE",
+		"PSUBSCRIBE", "MULTI", "EXEC", "DISCARD", "ASKING", "MODULE", "MONITOR":
+		return "", false
+	}
+	return args[1], true
+}
+
+// keySlot is redis' CRC16(key) mod 16384, honouring {hash tags}.
+func keySlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return int(crc16(key) % 16384)
+}
+
+// crc16 is CRC-16/XMODEM (poly 0x1021), the variant redis cluster uses.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for b := 0; b < 8; b++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// sumStats folds several pools' counters into one snapshot.
+func sumStats(nodes map[string]*respRedis) poolStats {
+	var out poolStats
+	addrs := make([]string, 0, len(nodes))
+	for addr, n := range nodes {
+		st := n.Stats()
+		addrs = append(addrs, addr)
+		out.Dials += st.Dials
+		out.Reuses += st.Reuses
+		out.Discards += st.Discards
+		out.Cancels += st.Cancels
+		out.Waits += st.Waits
+		out.Active += st.Active
+		out.Idle += st.Idle
+	}
+	sort.Strings(addrs)
+	out.Addr = strings.Join(addrs, ",")
+	return out
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestKeySlot pins CRC16/XMODEM and hash-tag handling against the
+// values in the redis cluster spec.
+func TestKeySlot(t *testing.T) {
+	if got := crc16("123456789"); got != 0x31C3 {
+		t.Errorf("crc16 check value = %#x, want 0x31c3", got)
+	}
+	if got := keySlot("foo"); got != 12182 {
+		t.Errorf("keySlot(foo) = %d, want 12182", got)
+	}
+	if keySlot("{user1000}.following") != keySlot("{user1000}.followers") {
+		t.Error("hash-tagged keys landed in different slots")
+	}
+	if keySlot("foo{}{bar}") != int(crc16("foo{}{bar}")%16384) {
+		t.Error("empty hash tag must hash the whole key")
+	}
+}
+
+func TestCommandKey(t *testing.T) {
+	cases := []struct {
+		args []string
+		key  string
+		ok   bool
+	}{
+		{[]string{"EVAL", "return 1", "1", "chain:counter", "x"}, "chain:counter", true},
+		{[]string{"EVALSHA", "abc", "0", "x"}, "", false},
+		{[]string{"EVAL", "return 1"}, "", false},
+		{[]string{"FCALL", "f", "2", "a", "b"}, "a", true},
+		{[]string{"GET", "k"}, "k", true},
+		{[]string{"SCRIPT", "LOAD", "return 1"}, "", false},
+		{[]string{"PING"}, "", false},
+	}
+	for _, tc := range cases {
+		key, ok := commandKey(tc.args)
+		if key != tc.key || ok != tc.ok {
+			t.Errorf("commandKey(%q) = %q,%t want %q,%t", tc.args, key, ok, tc.key, tc.ok)
+		}
+	}
+}
+
+// TestClusterRedis_FollowsMoved pins redirect handling: the seed owns
+// every slot per CLUSTER SHARDS, answers the EVAL with MOVED, and the
+// client re-issues it on the node named in the redirect.
+func TestClusterRedis_FollowsMoved(t *testing.T) {
+	other, otherCmds := fakeRedis(t, []string{":1\r\n"})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, seedCmds := serveFakeRedis(t, ln, []string{allSlots(ln.Addr().String()), "-MOVED 7 " + other + "\r\n"})
+
+	c := newClusterRedis([]string{seed}, redisOptions{})
+	v, err := c.Do(context.Background(), "EVAL", "return redis.call('INCR', KEYS[1])", "1", "chain:counter")
+	if err != nil || v.Int != 1 {
+		t.Fatalf("Do = %+v, %v; want 1 from the redirect target", v, err)
+	}
+	c.Close()
+	if seen := <-seedCmds; len(seen) != 2 || strings.Join(seen[0], " ") != "CLUSTER SHARDS" {
+		t.Errorf("seed saw %q, want CLUSTER SHARDS then EVAL", seen)
+	}
+	if seen := <-otherCmds; len(seen) != 1 || seen[0][0] != "EVAL" {
+		t.Errorf("redirect target saw %q, want the EVAL", seen)
+	}
+}
+
+// TestClusterRedis_PipelineMovedResendsWhole pins that a MOVED inside
+// a MULTI/EXEC pipeline re-learns the slot map and replays the whole
+// transaction on the new owner, never just the redirected command.
+func TestClusterRedis_PipelineMovedResendsWhole(t *testing.T) {
+	other, otherCmds := fakeRedis(t, []string{"+OK\r\n", "+QUEUED\r\n", "*1\r\n+OK\r\n"})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, seedCmds := serveFakeRedis(t, ln, []string{
+		allSlots(ln.Addr().String()),
+		"+OK\r\n", "-MOVED 7 " + other + "\r\n", "-EXECABORT Transaction discarded\r\n",
+		allSlots(other),
+	})
+
+	c := newClusterRedis([]string{seed}, redisOptions{})
+	replies, err := c.Pipeline(context.Background(), []string{"MULTI"}, []string{"SET", "chain:k", "1"}, []string{"EXEC"})
+	if err != nil || len(replies) != 3 || replies[1].Str != "QUEUED" {
+		t.Fatalf("Pipeline = %+v, %v; want the transaction from the new owner", replies, err)
+	}
+	if got := c.route([]string{"GET", "chain:k"}); got != other {
+		t.Errorf("route after MOVED = %s, want %s", got, other)
+	}
+	c.Close()
+	if seen := <-seedCmds; len(seen) != 5 || strings.Join(seen[4], " ") != "CLUSTER SHARDS" {
+		t.Errorf("seed saw %q, want CLUSTER SHARDS, the pipeline, then a re-learn", seen)
+	}
+	if seen := <-otherCmds; len(seen) != 3 || seen[0][0] != "MULTI" || seen[2][0] != "EXEC" {
+		t.Errorf("new owner saw %q, want the whole MULTI/SET/EXEC", seen)
+	}
+}
+
+// allSlots is a RESP2 CLUSTER SHARDS reply with addr serving every slot.
+func allSlots(addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	return "*1\r\n" +
+		"*4\r\n$5\r\nslots\r\n*2\r\n:0\r\n:16383\r\n" +
+		"$5\r\nnodes\r\n*1\r\n" +
+		"*8\r\n$2\r\nip\r\n" + bulk(host) + "$4\r\nport\r\n:" + port + "\r\n" +
+		"$4\r\nrole\r\n$6\r\nmaster\r\n$6\r\nhealth\r\n$6\r\nonline\r\n"
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
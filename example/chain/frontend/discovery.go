@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// backendRegistryKey is the sorted set chain-backend replicas
+// heartbeat into (REGISTRY_REDIS_ADDR on the backend): member
+// host:port, score the unix-ms time the entry expires.
+const backendRegistryKey = "chain:registry:backend"
+
+// discoveryBackend is the BACKEND_DISCOVERY=redis backendClient: it
+// round-robins requests over the live members of backendRegistryKey,
+// re-reading the set at most every refresh. When the registry is empty
+// or redis is unreachable it falls back to the static BACKEND_URL.
+//
+// Whatever host:port is in the set gets the frontend's traffic. An
+// EVAL that ZADDs its own pod into chain:registry:backend redirects
+// /api/products to it — a novel frontend egress edge, no process
+// spawned anywhere.
+type discoveryBackend struct {
+	rd       redisClient
+	client   *http.Client
+	fallback backendClient
+	refresh  time.Duration
+
+	mu      sync.Mutex
+	members []string
+	// checked is when the last registry read started, failed or not,
+	// so a down redis is retried once per refresh rather than on every
+	// request. reading marks a read in flight.
+	checked time.Time
+	reading bool
+	next    atomic.Uint64
+}
+
+func newDiscoveryBackend(rd redisClient, client *http.Client, fallback backendClient, refresh time.Duration) *discoveryBackend {
+	return &discoveryBackend{rd: rd, client: client, fallback: fallback, refresh: refresh}
+}
+
+// live returns the registered members, re-reading redis when the last
+// read is older than refresh. Only one caller reads at a time, and it
+// does so without the lock; everyone else gets the current list. A
+// failed read keeps the previous list until the next refresh.
+func (d *discoveryBackend) live(ctx context.Context) []string {
+	d.mu.Lock()
+	if d.reading || time.Since(d.checked) < d.refresh {
+		members := d.members
+		d.mu.Unlock()
+		return members
+	}
+	d.reading, d.checked = true, time.Now()
+	d.mu.Unlock()
+
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	v, err := d.rd.Do(ctx, "ZRANGEBYSCORE", backendRegistryKey, "("+now, "+inf")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reading = false
+	if err != nil {
+		log.Printf("WARN: backend registry: %v (retrying in %s)", err, d.refresh)
+		return d.members
+	}
+	members := make([]string, 0, len(v.Elems))
+	for _, e := range v.Elems {
+		members = append(members, e.String())
+	}
+	d.members = members
+	return members
+}
+
+// Get sends path to the next live member, trying the others in turn
+// when one can't be reached.
+func (d *discoveryBackend) Get(path string) (int, string, error) {
+	return d.Send(http.MethodGet, path, "", nil)
+}
+
+// Send is Get for any method. body is a byte slice so the request can
+// be replayed on the next member after a failed connect.
+func (d *discoveryBackend) Send(method, path, contentType string, body []byte) (int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	members := d.live(ctx)
+	if len(members) == 0 {
+		if method == http.MethodGet {
+			return d.fallback.Get(path)
+		}
+		s, ok := d.fallback.(backendSender)
+		if !ok {
+			return 0, "", errBackendReadOnly
+		}
+		return s.Send(method, path, contentType, body)
+	}
+	start := d.next.Add(1)
+	var lastErr error
+	for i := range members {
+		target := members[(start+uint64(i))%uint64(len(members))]
+		status, resp, err := sendHTTP(d.client, method, "http://"+target+path, contentType, body)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return status, resp, nil
+	}
+	return 0, "", lastErr
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func replicaServer(t *testing.T, name string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name))
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func members(addrs ...string) respValue {
+	v := respValue{Kind: respArray}
+	for _, a := range addrs {
+		v.Elems = append(v.Elems, respValue{Kind: respBulk, Str: a})
+	}
+	return v
+}
+
+// TestDiscovery_BalancesOverRegistry pins the redirect surface: the
+// frontend sends traffic to whatever host:port chain:registry:backend
+// lists, spreading requests across the members.
+func TestDiscovery_BalancesOverRegistry(t *testing.T) {
+	a, b := replicaServer(t, "a"), replicaServer(t, "b")
+	rd := &stubRedis{reply: members(a, b)}
+	d := newDiscoveryBackend(rd, http.DefaultClient, &stubBackend{}, time.Minute)
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		status, body, err := d.Get("/api/products")
+		if err != nil || status != 200 {
+			t.Fatalf("Get = %d, %v", status, err)
+		}
+		seen[body]++
+	}
+	if seen["a"] != 2 || seen["b"] != 2 {
+		t.Errorf("requests per replica = %v, want 2 each", seen)
+	}
+	if rd.lastCmd[0] != "ZRANGEBYSCORE" || rd.lastCmd[1] != backendRegistryKey || rd.lastCmd[3] != "+inf" {
+		t.Errorf("registry read = %q", rd.lastCmd)
+	}
+}
+
+func TestDiscovery_SkipsUnreachableMember(t *testing.T) {
+	ok := replicaServer(t, "ok")
+	rd := &stubRedis{reply: members("127.0.0.1:1", ok)}
+	d := newDiscoveryBackend(rd, http.DefaultClient, &stubBackend{}, time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, body, err := d.Get("/api/products"); err != nil || body != "ok" {
+			t.Errorf("Get = %q, %v; want the reachable replica", body, err)
+		}
+	}
+}
+
+func TestDiscovery_EmptyRegistryFallsBack(t *testing.T) {
+	fallback := &stubBackend{}
+	d := newDiscoveryBackend(&stubRedis{reply: members()}, http.DefaultClient, fallback, time.Minute)
+	if status, _, err := d.Get("/api/products"); err != nil || status != 200 || fallback.lastPath != "/api/products" {
+		t.Errorf("Get = %d, %v; fallback saw %q", status, err, fallback.lastPath)
+	}
+}
+
+// downRedis is an unreachable redis that counts the commands it was
+// asked to run.
+type downRedis struct {
+	stubRedis
+	calls atomic.Int32
+}
+
+func (d *downRedis) Do(context.Context, ...string) (respValue, error) {
+	d.calls.Add(1)
+	return respValue{}, errors.New("dial tcp: connection refused")
+}
+
+// TestDiscovery_RedisDownBacksOff pins that a dead registry costs one
+// redis attempt per refresh, not one per request.
+func TestDiscovery_RedisDownBacksOff(t *testing.T) {
+	rd := &downRedis{}
+	fallback := &stubBackend{}
+	d := newDiscoveryBackend(rd, http.DefaultClient, fallback, time.Minute)
+	for i := 0; i < 5; i++ {
+		if status, _, err := d.Get("/api/products"); err != nil || status != 200 {
+			t.Fatalf("Get = %d, %v; want the static fallback", status, err)
+		}
+	}
+	if n := rd.calls.Load(); n != 1 {
+		t.Errorf("registry reads = %d, want 1 within one refresh", n)
+	}
+}
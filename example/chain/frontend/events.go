@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pubsubMessage is one frame a subscribed connection receives: a
+// "message" / "pmessage" delivery, or a "subscribe" / "psubscribe"
+// confirmation (Payload is then the subscription count). Keyspace
+// notifications additionally carry the key and the event name.
+type pubsubMessage struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern,omitempty"`
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+	Key     string `json:"key,omitempty"`
+	Event   string `json:"event,omitempty"`
+}
+
+// parsePubsub recognises pub/sub frames: arrays in RESP2, pushes in
+// RESP3, same element layout either way.
+func parsePubsub(v respValue) (pubsubMessage, bool) {
+	if (v.Kind != respArray && v.Kind != respPush) || len(v.Elems) < 3 {
+		return pubsubMessage{}, false
+	}
+	m := pubsubMessage{Kind: strings.ToLower(v.Elems[0].String())}
+	switch {
+	case m.Kind == "pmessage" && len(v.Elems) == 4:
+		m.Pattern, m.Channel, m.Payload = v.Elems[1].String(), v.Elems[2].String(), v.Elems[3].String()
+	case m.Kind == "message" || m.Kind == "subscribe" || m.Kind == "psubscribe":
+		m.Channel, m.Payload = v.Elems[1].String(), v.Elems[2].String()
+	default:
+		return pubsubMessage{}, false
+	}
+	// __keyspace@<db>__:<key> carries the event as payload.
+	if rest, ok := strings.CutPrefix(m.Channel, "__keyspace@"); ok && m.Kind != "subscribe" && m.Kind != "psubscribe" {
+		if _, key, ok := strings.Cut(rest, "__:"); ok {
+			m.Key, m.Event = key, m.Payload
+		}
+	}
+	return m, true
+}
+
+// Subscribe issues SUBSCRIBE / PSUBSCRIBE on a dedicated connection
+// and calls fn for every frame until ctx is done (returns nil) or the
+// connection fails.
+func (r *respRedis) Subscribe(ctx context.Context, channels, patterns []string, fn func(pubsubMessage)) error {
+	var cmds [][]string
+	if len(channels) > 0 {
+		cmds = append(cmds, append([]string{"SUBSCRIBE"}, channels...))
+	}
+	if len(patterns) > 0 {
+		cmds = append(cmds, append([]string{"PSUBSCRIBE"}, patterns...))
+	}
+	if len(cmds) == 0 {
+		return errors.New("subscribe: no channels or patterns")
+	}
+	return r.dedicated(ctx, cmds, func(v respValue) error {
+		if err := v.Err(); err != nil {
+			return err
+		}
+		if m, ok := parsePubsub(v); ok {
+			fn(m)
+		}
+		return nil
+	})
+}
+
+// wsPingInterval keeps intermediaries (ingress, kube-proxy conntrack)
+// from reaping an idle /api/events socket.
+const wsPingInterval = 30 * time.Second
+
+// registerEventRoutes wires GET /api/events, a WebSocket that relays
+// redis pub/sub to the browser as JSON text frames (one pubsubMessage
+// each). Query parameters, all repeatable:
+//
+//   - channel=<name>    SUBSCRIBE
+//   - pattern=<glob>    PSUBSCRIBE
+//   - keyspace=<glob>   PSUBSCRIBE __keyspace@0__:<glob>; needs
+//     notify-keyspace-events on the redis side (chain.yaml sets it)
+//
+// With none given it watches keyspace=chain:*. Each socket holds one
+// long-lived, mostly idle frontend → redis connection for as long as
+// the browser stays.
+func registerEventRoutes(mux *http.ServeMux, rd redisClient) {
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		channels, patterns := q["channel"], q["pattern"]
+		keyspace := q["keyspace"]
+		if len(channels)+len(patterns)+len(keyspace) == 0 {
+			keyspace = []string{"chain:*"}
+		}
+		for _, k := range keyspace {
+			patterns = append(patterns, "__keyspace@0__:"+k)
+		}
+
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		// The hijacked connection outlives nothing in net/http that
+		// would cancel r.Context(); the reader below cancels instead.
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		defer cancel()
+
+		go func() {
+			defer cancel()
+			for {
+				op, payload, err := ws.readFrame()
+				if err != nil {
+					return
+				}
+				switch op {
+				case wsOpPing:
+					_ = ws.writeFrame(wsOpPong, payload)
+				case wsOpClose:
+					_ = ws.writeFrame(wsOpClose, payload)
+					return
+				}
+			}
+		}()
+
+		msgs := make(chan pubsubMessage, 64)
+		subErr := make(chan error, 1)
+		go func() {
+			subErr <- rd.Subscribe(ctx, channels, patterns, func(m pubsubMessage) {
+				select {
+				case msgs <- m:
+				case <-ctx.Done():
+				}
+			})
+		}()
+
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case m := <-msgs:
+				b, _ := json.Marshal(m)
+				if err := ws.writeFrame(wsOpText, b); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := ws.writeFrame(wsOpPing, nil); err != nil {
+					return
+				}
+			case err := <-subErr:
+				if err != nil {
+					log.Printf("events: subscribe: %v", err)
+					_ = ws.writeClose(1011, err.Error())
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
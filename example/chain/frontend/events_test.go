@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRespRedis_SubscribeDeliversMessages pins the subscriber leg:
+// SUBSCRIBE and PSUBSCRIBE go out on a dedicated connection, the
+// confirmations and deliveries come back in order, and keyspace
+// channels are decoded into key + event.
+func TestRespRedis_SubscribeDeliversMessages(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{
+		"*3\r\n$9\r\nsubscribe\r\n$5\r\nchain\r\n:1\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$5\r\nchain\r\n$2\r\nhi\r\n",
+		"*3\r\n$10\r\npsubscribe\r\n$22\r\n__keyspace@0__:chain:*\r\n:2\r\n" +
+			"*4\r\n$8\r\npmessage\r\n$22\r\n__keyspace@0__:chain:*\r\n$28\r\n__keyspace@0__:chain:counter\r\n$4\r\nincr\r\n",
+	})
+	rd := newRespRedis(addr, redisOptions{})
+	var got []pubsubMessage
+	err := rd.Subscribe(context.Background(), []string{"chain"}, []string{"__keyspace@0__:chain:*"},
+		func(m pubsubMessage) { got = append(got, m) })
+	if err == nil {
+		t.Error("Subscribe returned nil after the server hung up, want the read error")
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d messages, want 4: %+v", len(got), got)
+	}
+	if got[1].Kind != "message" || got[1].Payload != "hi" {
+		t.Errorf("message = %+v", got[1])
+	}
+	if got[3].Key != "chain:counter" || got[3].Event != "incr" {
+		t.Errorf("keyspace = %+v, want key chain:counter event incr", got[3])
+	}
+	seen := <-cmds
+	if len(seen) != 2 || strings.Join(seen[0], " ") != "SUBSCRIBE chain" || seen[1][0] != "PSUBSCRIBE" {
+		t.Errorf("server saw %q", seen)
+	}
+}
+
+// TestEvents_WebSocketRelaysMessages pins /api/events end to end over
+// a real socket: the RFC 6455 handshake, keyspace= becoming a
+// PSUBSCRIBE pattern, and each message arriving as a JSON text frame.
+func TestEvents_WebSocketRelaysMessages(t *testing.T) {
+	rd := &stubRedis{messages: []pubsubMessage{{Kind: "message", Channel: "chain", Payload: "hello"}}}
+	srv := httptest.NewServer(newServer(nil, rd))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, _ = io.WriteString(conn, "GET /api/events?keyspace=cart:* HTTP/1.1\r\nHost: x\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %d %q", resp.StatusCode, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	var hdr [2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		t.Fatal(err)
+	}
+	if hdr[0] != 0x80|wsOpText || hdr[1]&0x80 != 0 {
+		t.Fatalf("frame header %x, want unmasked FIN text", hdr)
+	}
+	payload := make([]byte, hdr[1])
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	var m pubsubMessage
+	if err := json.Unmarshal(payload, &m); err != nil || m.Payload != "hello" {
+		t.Fatalf("frame = %s (%v), want the relayed message", payload, err)
+	}
+
+	// Masked close from the client; the server echoes it and hangs up.
+	mask := []byte{1, 2, 3, 4}
+	body := binary.BigEndian.AppendUint16(nil, 1000)
+	for i := range body {
+		body[i] ^= mask[i%4]
+	}
+	_, _ = conn.Write(append(append([]byte{0x80 | wsOpClose, 0x80 | byte(len(body))}, mask...), body...))
+	if _, err := io.ReadFull(br, hdr[:]); err != nil || hdr[0]&0x0F != wsOpClose {
+		t.Errorf("after close got %x, %v; want a close frame", hdr, err)
+	}
+	if rd.subscribed[1][0] != "__keyspace@0__:cart:*" {
+		t.Errorf("patterns = %q, want the keyspace pattern", rd.subscribed[1])
+	}
+}
+
+func TestEvents_PlainGETNeedsUpgrade(t *testing.T) {
+	srv := newServer(nil, &stubRedis{})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if rec.Code != http.StatusUpgradeRequired {
+		t.Errorf("status = %d, want 426", rec.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// flagsKey is the redis hash the frontend polls for feature flags:
+//
+//	HSET chain:flags debug_landing 1 verbose_errors 0 products_cache_ttl 5m
+//
+// Missing fields keep their defaults; a value that doesn't parse is
+// logged and ignored.
+const flagsKey = "chain:flags"
+
+// featureFlags are the runtime toggles. They live in the same redis
+// /api/cache/eval can write to, so a script that HSETs chain:flags
+// changes how the shop behaves — no process spawned, nothing for a
+// kernel-level sensor to see. That config-poisoning stage is the point.
+type featureFlags struct {
+	// DebugLanding adds the flags, script SHAs and pool counters to
+	// GET /.
+	DebugLanding bool
+	// VerboseErrors puts the underlying error text in error bodies;
+	// off, callers get a generic message.
+	VerboseErrors bool
+	// ProductsCacheTTL is the /api/products cache lifetime; 0 bypasses
+	// the cache. Off by default: a cache collapses loadgen's catalog
+	// reads to one backend → postgres query per TTL, which would thin
+	// the profile those pods learn.
+	ProductsCacheTTL time.Duration
+}
+
+// defaultFlags apply until redis says otherwise, and to requests that
+// never passed through a flagStore (tests).
+var defaultFlags = featureFlags{VerboseErrors: true}
+
+// flagStore holds the current flags, refreshed from flagsKey.
+type flagStore struct {
+	rd       redisClient
+	defaults featureFlags
+	cur      atomic.Pointer[flagSnapshot]
+}
+
+// flagSnapshot is what /api/flags serves.
+type flagSnapshot struct {
+	featureFlags
+	Source   string    // "defaults" or "redis"
+	LoadedAt time.Time // zero until the first refresh
+}
+
+func newFlagStore(rd redisClient, defaults featureFlags) *flagStore {
+	fs := &flagStore{rd: rd, defaults: defaults}
+	fs.cur.Store(&flagSnapshot{featureFlags: defaults, Source: "defaults"})
+	return fs
+}
+
+// Get returns the current flags.
+func (fs *flagStore) Get() featureFlags {
+	return fs.cur.Load().featureFlags
+}
+
+// refresh reads flagsKey with HGETALL and swaps the flags in. An empty
+// or missing hash means the defaults.
+func (fs *flagStore) refresh(ctx context.Context) error {
+	v, err := fs.rd.Do(ctx, "HGETALL", flagsKey)
+	if err != nil {
+		return err
+	}
+	f := fs.defaults
+	fields := map[string]string{}
+	for i := 0; i+1 < len(v.Elems); i += 2 {
+		fields[v.Elems[i].String()] = v.Elems[i+1].String()
+	}
+	for name, raw := range fields {
+		var err error
+		switch name {
+		case "debug_landing":
+			var b bool
+			if b, err = strconv.ParseBool(raw); err == nil {
+				f.DebugLanding = b
+			}
+		case "verbose_errors":
+			var b bool
+			if b, err = strconv.ParseBool(raw); err == nil {
+				f.VerboseErrors = b
+			}
+		case "products_cache_ttl":
+			var d time.Duration
+			if d, err = parseTTL(raw); err == nil {
+				f.ProductsCacheTTL = d
+			}
+		}
+		if err != nil {
+			log.Printf("WARN: %s %s=%q: %v (keeping default)", flagsKey, name, raw, err)
+		}
+	}
+	source := "defaults"
+	if len(fields) > 0 {
+		source = "redis"
+	}
+	fs.cur.Store(&flagSnapshot{featureFlags: f, Source: source, LoadedAt: time.Now()})
+	return nil
+}
+
+// parseTTL accepts a Go duration ("90s") or whole seconds ("90").
+func parseTTL(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// run refreshes every interval until ctx is done. A failed refresh
+// keeps the last flags.
+func (fs *flagStore) run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		rctx, cancel := context.WithTimeout(ctx, every)
+		if err := fs.refresh(rctx); err != nil && ctx.Err() == nil {
+			log.Printf("WARN: refresh %s: %v", flagsKey, err)
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type flagsCtxKey struct{}
+
+// wrap makes the current flags available to handlers through
+// flagsFrom(r.Context()).
+func (fs *flagStore) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), flagsCtxKey{}, fs.Get())))
+	})
+}
+
+// flagsFrom returns the flags the request was served with.
+func flagsFrom(ctx context.Context) featureFlags {
+	if f, ok := ctx.Value(flagsCtxKey{}).(featureFlags); ok {
+		return f
+	}
+	return defaultFlags
+}
+
+// errorText is err's message, or a generic one when verbose_errors is
+// off.
+func errorText(r *http.Request, err error) string {
+	if flagsFrom(r.Context()).VerboseErrors {
+		return err.Error()
+	}
+	return "internal error"
+}
+
+// registerFlagRoutes wires GET /api/flags, the active flags and where
+// they came from.
+func registerFlagRoutes(mux *http.ServeMux, fs *flagStore) {
+	mux.HandleFunc("GET /api/flags", func(w http.ResponseWriter, r *http.Request) {
+		s := fs.cur.Load()
+		out := map[string]any{
+			"debug_landing":      s.DebugLanding,
+			"verbose_errors":     s.VerboseErrors,
+			"products_cache_ttl": s.ProductsCacheTTL.String(),
+			"source":             s.Source,
+		}
+		if !s.LoadedAt.IsZero() {
+			out["loaded_at"] = s.LoadedAt.UTC().Format(time.RFC3339)
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hash(kv ...string) respValue {
+	v := respValue{Kind: respArray}
+	for _, s := range kv {
+		v.Elems = append(v.Elems, respValue{Kind: respBulk, Str: s})
+	}
+	return v
+}
+
+// TestFlags_PoisonedHashChangesBehavior is the config-poisoning
+// contract: whatever lands in chain:flags — including an attacker's
+// HSET through /api/cache/eval — is what the frontend runs with after
+// the next refresh. Validating or signing flags here removes the stage.
+func TestFlags_PoisonedHashChangesBehavior(t *testing.T) {
+	rd := &stubRedis{reply: hash("debug_landing", "1", "verbose_errors", "false", "products_cache_ttl", "0")}
+	fs := newFlagStore(rd, defaultFlags)
+	if err := fs.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rd.lastCmd[0] != "HGETALL" || rd.lastCmd[1] != "chain:flags" {
+		t.Errorf("cmd = %q", rd.lastCmd)
+	}
+	want := featureFlags{DebugLanding: true, VerboseErrors: false, ProductsCacheTTL: 0}
+	if got := fs.Get(); got != want {
+		t.Fatalf("flags = %+v, want %+v", got, want)
+	}
+
+	srv := newServer(&stubBackend{}, &stubRedis{err: errors.New("NOAUTH secret-ish detail")}, withFlags(fs))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/eval", strings.NewReader(`{"script":"return 1"}`)))
+	if strings.Contains(rec.Body.String(), "NOAUTH") {
+		t.Errorf("verbose_errors=false still leaked %q", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), "<h2>debug</h2>") {
+		t.Errorf("debug_landing=1 but landing page has no debug section")
+	}
+}
+
+func TestFlags_BadValuesKeepDefaults(t *testing.T) {
+	rd := &stubRedis{reply: hash("verbose_errors", "maybe", "products_cache_ttl", "90", "unknown", "x")}
+	fs := newFlagStore(rd, defaultFlags)
+	if err := fs.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := fs.Get()
+	if !got.VerboseErrors || got.ProductsCacheTTL != 90*time.Second {
+		t.Errorf("flags = %+v, want verbose kept and a 90s TTL", got)
+	}
+}
+
+func TestFlagsEndpoint(t *testing.T) {
+	rd := &stubRedis{reply: hash("products_cache_ttl", "5m")}
+	fs := newFlagStore(rd, defaultFlags)
+	srv := newServer(nil, rd, withFlags(fs))
+
+	get := func() map[string]any {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flags", nil))
+		var got map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("body %q: %v", rec.Body.String(), err)
+		}
+		return got
+	}
+	if got := get(); got["source"] != "defaults" || got["products_cache_ttl"] != "0s" {
+		t.Errorf("before refresh = %v", got)
+	}
+	if err := fs.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got["source"] != "redis" || got["products_cache_ttl"] != "5m0s" || got["loaded_at"] == nil {
+		t.Errorf("after refresh = %v", got)
+	}
+}
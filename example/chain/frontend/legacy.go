@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// inlineRedis is implemented by clients that can send a raw inline
+// command line — the pre-RESP "GET key\r\n" form telnet and very old
+// client libraries use. /api/cache/get and /api/cache/set need it and
+// answer 501 for the others.
+type inlineRedis interface {
+	Inline(ctx context.Context, line string) (respValue, error)
+}
+
+// Inline writes line plus CRLF on a dedicated connection and returns
+// the first reply. Nothing is escaped or length-prefixed: a line that
+// itself contains CRLF is several commands to redis, all of which run;
+// only the first one's reply is read before the connection is closed.
+// The connection is never pooled, so the unread replies can't poison
+// later requests.
+func (r *respRedis) Inline(ctx context.Context, line string) (respValue, error) {
+	c, err := r.dial(ctx)
+	if err != nil {
+		return respValue{}, err
+	}
+	r.dials.Add(1)
+	defer c.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(r.opts.Timeout)
+	}
+	var v respValue
+	// c is closed on return, so a late cancellation hook is harmless.
+	_, err = r.guard(ctx, c, deadline, func() error {
+		if _, err := c.bw.WriteString(line + "\r\n"); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		if err := c.bw.Flush(); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		var err error
+		v, err = readRespReply(c.br, r.opts.Limits)
+		return err
+	})
+	if err != nil {
+		return respValue{}, err
+	}
+	return v, v.Err()
+}
+
+// registerLegacyRoutes wires the "legacy" key/value cache, kept for an
+// old storefront build that predates /api/cache/eval:
+//
+//   - GET  /api/cache/get?key=K             → GET K
+//   - POST /api/cache/set?key=K&value=V[&ttl=S] → SET K V [EX S]
+//
+// The command is built by string concatenation and sent inline, the
+// way the original client did it. A key (or value) containing "\r\n"
+// therefore smuggles whole extra commands into redis — a protocol-
+// level injection that needs no Lua and no sandbox escape
+// (GET /api/cache/get?key=x%0D%0ACONFIG%20SET%20dir%20/tmp). Quoting
+// or rejecting CRLF here removes that chain stage — see
+// TestCacheGet_KeyCRLFReachesRedis.
+func registerLegacyRoutes(mux *http.ServeMux, rd redisClient) {
+	inline := func(w http.ResponseWriter, r *http.Request, line string) {
+		ir, ok := rd.(inlineRedis)
+		if !ok {
+			http.Error(w, "redis client cannot send inline commands", http.StatusNotImplemented)
+			return
+		}
+		reply, err := ir.Inline(r.Context(), line)
+		writeReply(w, r, reply, err)
+	}
+
+	mux.HandleFunc("GET /api/cache/get", func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key is required", http.StatusBadRequest)
+			return
+		}
+		inline(w, r, "GET "+key)
+	})
+
+	mux.HandleFunc("POST /api/cache/set", func(w http.ResponseWriter, r *http.Request) {
+		key, value := r.FormValue("key"), r.FormValue("value")
+		if key == "" {
+			http.Error(w, "key is required", http.StatusBadRequest)
+			return
+		}
+		line := "SET " + key + " " + value
+		if ttl := r.FormValue("ttl"); ttl != "" {
+			if _, err := strconv.Atoi(ttl); err != nil {
+				http.Error(w, "ttl must be an integer", http.StatusBadRequest)
+				return
+			}
+			line += " EX " + ttl
+		}
+		inline(w, r, line)
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// inlineStub records the raw line the legacy endpoints built.
+type inlineStub struct {
+	stubRedis
+	line string
+}
+
+func (s *inlineStub) Inline(_ context.Context, line string) (respValue, error) {
+	s.line = line
+	return s.reply, s.err
+}
+
+// TestCacheGet_KeyCRLFReachesRedis is the legacy cache's twin of
+// TestCacheEval_AttackerScriptReachesRedis: a key carrying CRLF and a
+// second command MUST reach the inline line byte for byte. If anyone
+// escapes, quotes or rejects CR/LF here, the command-injection stage
+// disappears from the chain.
+func TestCacheGet_KeyCRLFReachesRedis(t *testing.T) {
+	rd := &inlineStub{stubRedis: stubRedis{reply: respValue{Kind: respNil}}}
+	srv := newServer(nil, rd)
+
+	evil := "chain:x\r\nCONFIG SET dir /tmp"
+	req := httptest.NewRequest(http.MethodGet, "/api/cache/get?key="+url.QueryEscape(evil), nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if rd.line != "GET "+evil {
+		t.Errorf("inline line = %q — frontend MUST concatenate the key verbatim", rd.line)
+	}
+}
+
+func TestCacheSet_BuildsInlineLine(t *testing.T) {
+	rd := &inlineStub{stubRedis: stubRedis{reply: respValue{Kind: respSimple, Str: "OK"}}}
+	srv := newServer(nil, rd)
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/set?key=chain:greeting&value=hi&ttl=60", nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rd.line != "SET chain:greeting hi EX 60" {
+		t.Errorf("inline line = %q", rd.line)
+	}
+}
+
+// TestRespRedis_InlineWritesRawLine pins the wire side: the line goes
+// out unframed, so redis sees the smuggled command as a command of its
+// own, and the caller still gets the first reply.
+func TestRespRedis_InlineWritesRawLine(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	wire := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		first, _ := br.ReadString('\n')
+		second, _ := br.ReadString('\n')
+		_, _ = io.WriteString(conn, "$-1\r\n+OK\r\n")
+		wire <- first + second
+	}()
+
+	rd := newRespRedis(ln.Addr().String(), redisOptions{})
+	v, err := rd.Inline(context.Background(), "GET chain:x\r\nCONFIG SET dir /tmp")
+	if err != nil || v.Kind != respNil {
+		t.Fatalf("Inline = %+v, %v; want the GET's nil", v, err)
+	}
+	if got := <-wire; got != "GET chain:x\r\nCONFIG SET dir /tmp\r\n" {
+		t.Errorf("wire = %q", got)
+	}
+	if !strings.Contains(rd.Stats().Addr, "127.0.0.1") || rd.Stats().Idle != 0 {
+		t.Errorf("inline connection was pooled: %+v", rd.Stats())
+	}
+}
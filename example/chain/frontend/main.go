@@ -1,13 +1,58 @@
 // chain-frontend is the public-facing HTTP entrypoint for the chain
 // demo. It exposes:
 //   - GET  /                 → simple landing HTML
-//   - GET  /api/products     → proxies to chain-backend (HTTP)
+//   - GET  /api/products     → proxies to chain-backend (HTTP; with
+//                              BACKEND_DISCOVERY=redis, balanced over
+//                              the chain:registry:backend replicas),
+//                              read through a cache (redis GET/SETEX or
+//                              memcached, CACHE_BACKEND; products_cache_ttl
+//                              flag; invalidated by PUBLISH
+//                              chain:cache:invalidate <path>)
+//   - /api/cart/{user}[/items[/{sku}]|/checkout] → proxies the
+//                              shopper's cart to chain-backend, which
+//                              keeps it in redis hashes
 //   - POST /api/cache/eval   → proxies user-supplied Lua to redis EVAL
 //                              (legitimate atomic-counter pattern, but
 //                              the script is untrusted — this is the
-//                              chain demo's attack vector)
+//                              chain demo's attack vector); script,
+//                              keys and args also take base64 as
+//                              script_b64 / keys_b64 / args_b64, and
+//                              "encoding":"base64" encodes the reply
+//   - POST /api/cache/eval/stream → same EVAL, progress streamed as
+//                              Server-Sent Events (connected, sent,
+//                              partial, reply — all timestamped)
+//   - POST /api/cache/call/{name} → runs an approved named script via
+//                              EVALSHA (EVAL fallback on NOSCRIPT)
+//   - GET  /api/cache/scripts → lists the approved scripts and SHAs
+//   - POST /api/cache/function/load, GET /api/cache/function/list,
+//     POST /api/cache/fcall  → Redis 7 Functions (FUNCTION LOAD
+//                              REPLACE / FCALL / FCALL_RO); library
+//                              code is forwarded verbatim like EVAL
+//   - POST /api/cache/cmd    → raw RESP command passthrough; only
+//                              registered when CACHE_RAW_COMMANDS=true,
+//                              connection-state commands (MULTI,
+//                              SELECT, SUBSCRIBE, …) refused
+//   - GET  /api/cache/get, POST /api/cache/set → legacy inline
+//                              GET/SET built by string concatenation
+//                              (CRLF in a key smuggles extra commands)
+//   - POST /api/cache/counter/{name} → increments chain:counter:<name>
+//                              on CACHE_BACKEND (redis INCRBY or
+//                              memcached incr)
+//   - POST /api/cache/batch  → several commands as one pipeline or
+//                              one WATCH/MULTI/EXEC transaction
+//   - GET  /api/cache/pool   → redis connection-pool counters
+//   - GET  /api/admin/monitor → MONITOR feed as Server-Sent Events
+//                              (ops debugging; shows every client's
+//                              commands, AUTH included)
+//   - GET  /api/events       → WebSocket relaying redis pub/sub and
+//                              keyspace notifications to the browser
+//   - GET  /api/flags        → active feature flags (chain:flags hash)
 //   - GET  /healthz          → readiness
 //
+// Every route but /healthz sits behind a per-client sliding-window
+// rate limiter run as the registered "ratelimit" Lua script
+// (RATE_LIMIT requests per RATE_LIMIT_WINDOW; 429 + Retry-After).
+//
 // "Legitimate but dangerous": the eval endpoint mirrors a pattern real
 // apps use for atomic ops (rate-limit windows, distributed counters).
 // The vuln is that the script content reaches redis EVAL unfiltered.
@@ -16,12 +61,16 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -34,14 +83,105 @@ type backendClient interface {
 	Get(path string) (int, string, error)
 }
 
+// backendSender is the optional write side of a backendClient, used to
+// proxy /api/cart. Without it those routes answer 501.
+type backendSender interface {
+	Send(method, path, contentType string, body []byte) (int, string, error)
+}
+
+// errBackendReadOnly is Send through a backendClient that can only Get.
+var errBackendReadOnly = errors.New("backend client is read-only")
+
 // redisClient is the surface for EVAL; stubbed in tests. Args are the
 // raw RESP command vector — first element is "EVAL", second the
 // script, third the numkeys (as decimal string), then keys, then argv.
+// The reply comes back as a typed tree (see respValue) so nil, integer,
+// bulk and nested-array results survive the trip to JSON intact.
+//
+// Both methods take the request context: when the HTTP client hangs
+// up, the in-flight command is abandoned rather than left to run out
+// its deadline. Pipeline sends several commands in one write and
+// returns one reply per command (error replies in place). Subscribe
+// holds a connection in SUBSCRIBE / PSUBSCRIBE mode and calls fn per
+// message until ctx is done.
 type redisClient interface {
-	Do(args ...string) (string, error)
+	Do(ctx context.Context, args ...string) (respValue, error)
+	Pipeline(ctx context.Context, cmds ...[]string) ([]respValue, error)
+	Subscribe(ctx context.Context, channels, patterns []string, fn func(pubsubMessage)) error
+}
+
+// poolStatser is implemented by pooled redis clients; /api/cache/pool
+// serves its snapshot so load tests can tell redis latency from
+// connection churn.
+type poolStatser interface {
+	Stats() poolStats
+}
+
+// serverConfig carries the optional wiring for newServer. Tests pass
+// no options and get the defaults.
+type serverConfig struct {
+	scripts     *scriptRegistry
+	heartbeat   time.Duration
+	rawCommands bool
+	rateLimit   int
+	rateWindow  time.Duration
+	flags       *flagStore
+	cache       cacheStore
+}
+
+type serverOption func(*serverConfig)
+
+// withScripts shares the registry main() already SCRIPT LOADed.
+func withScripts(reg *scriptRegistry) serverOption {
+	return func(c *serverConfig) { c.scripts = reg }
+}
+
+// withStreamHeartbeat sets how often /api/cache/eval/stream emits a
+// "waiting" event while redis is silent.
+func withStreamHeartbeat(d time.Duration) serverOption {
+	return func(c *serverConfig) { c.heartbeat = d }
+}
+
+// withRawCommands registers /api/cache/cmd.
+func withRawCommands(enabled bool) serverOption {
+	return func(c *serverConfig) { c.rawCommands = enabled }
+}
+
+// withRateLimit puts every route but /healthz behind the sliding-window
+// limiter: limit requests per client IP per window. limit <= 0 leaves
+// it off.
+func withRateLimit(limit int, window time.Duration) serverOption {
+	return func(c *serverConfig) { c.rateLimit, c.rateWindow = limit, window }
 }
 
-func newServer(be backendClient, rd redisClient) http.Handler {
+// withFlags shares the flag store main() keeps refreshed from redis.
+func withFlags(fs *flagStore) serverOption {
+	return func(c *serverConfig) { c.flags = fs }
+}
+
+// withCacheStore puts the counter endpoint on store instead of redis.
+func withCacheStore(store cacheStore) serverOption {
+	return func(c *serverConfig) { c.cache = store }
+}
+
+func newServer(be backendClient, rd redisClient, opts ...serverOption) http.Handler {
+	cfg := serverConfig{}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.scripts == nil {
+		cfg.scripts = newScriptRegistry(defaultScripts)
+	}
+	if cfg.heartbeat <= 0 {
+		cfg.heartbeat = 2 * time.Second
+	}
+	if cfg.flags == nil {
+		cfg.flags = newFlagStore(rd, defaultFlags)
+	}
+	if cfg.cache == nil {
+		cfg.cache = redisStore{rd}
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
@@ -56,13 +196,31 @@ func newServer(be backendClient, rd redisClient) http.Handler {
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		_, _ = w.Write([]byte(`<!doctype html><title>chain</title><h1>chain frontend</h1>` +
-			`<p>GET /api/products · POST /api/cache/eval</p>`))
+			`<p>GET /api/products · POST /api/cache/eval · POST /api/cache/call/{name}</p>`))
+		if f := flagsFrom(r.Context()); f.DebugLanding {
+			debug := map[string]any{
+				"flags":   map[string]any{"verbose_errors": f.VerboseErrors, "products_cache_ttl": f.ProductsCacheTTL.String()},
+				"scripts": cfg.scripts.List(),
+			}
+			if ps, ok := rd.(poolStatser); ok {
+				debug["pool"] = ps.Stats()
+			}
+			b, _ := json.MarshalIndent(debug, "", "  ")
+			_, _ = w.Write([]byte("<h2>debug</h2><pre>" + html.EscapeString(string(b)) + "</pre>"))
+		}
 	})
 
 	mux.HandleFunc("/api/products", func(w http.ResponseWriter, r *http.Request) {
-		status, body, err := be.Get("/api/products")
+		// The query (q, page, per_page, sort, dir) goes to the backend
+		// untouched — including sort's ORDER BY sink. cachedBackend only
+		// caches the bare path, so every query string reaches postgres.
+		path := "/api/products"
+		if r.URL.RawQuery != "" {
+			path += "?" + r.URL.RawQuery
+		}
+		status, body, err := be.Get(path)
 		if err != nil {
-			http.Error(w, "backend unreachable: "+err.Error(), http.StatusBadGateway)
+			http.Error(w, "backend unreachable: "+errorText(r, err), http.StatusBadGateway)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -71,135 +229,238 @@ func newServer(be backendClient, rd redisClient) http.Handler {
 	})
 
 	mux.HandleFunc("/api/cache/eval", func(w http.ResponseWriter, r *http.Request) {
-		var req struct {
-			Script string   `json:"script"`
-			Keys   []string `json:"keys,omitempty"`
-			Args   []string `json:"args,omitempty"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-		if req.Script == "" {
-			http.Error(w, "script is required", http.StatusBadRequest)
+		req, ok := readEvalRequest(w, r)
+		if !ok {
 			return
 		}
 		// Build the RESP EVAL: EVAL <script> <numkeys> <keys...> <args...>
 		// Script is forwarded VERBATIM by design.
-		respArgs := []string{"EVAL", req.Script, strconv.Itoa(len(req.Keys))}
-		respArgs = append(respArgs, req.Keys...)
-		respArgs = append(respArgs, req.Args...)
-		reply, err := rd.Do(respArgs...)
-		if err != nil {
-			// Return 200 with the redis error in body so the runner sees the
-			// underlying complaint (helps demo debugging) without
-			// classifying the attack as a transport failure.
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			_, _ = fmt.Fprintf(w, `{"error":%q}`, err.Error())
+		reply, err := rd.Do(r.Context(), scriptCommand("EVAL", req.Script, req.Keys, req.Args)...)
+		writeReply(w, r, req.encode(reply), err)
+	})
+
+	registerStreamRoutes(mux, rd, cfg.heartbeat)
+
+	// Registered-script path: only names in the registry run, by SHA.
+	mux.HandleFunc("/api/cache/call/{name}", func(w http.ResponseWriter, r *http.Request) {
+		script := cfg.scripts.Lookup(r.PathValue("name"))
+		if script == nil {
+			http.Error(w, "unknown script "+strconv.Quote(r.PathValue("name")), http.StatusNotFound)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, _ = fmt.Fprintf(w, `{"reply":%q}`, reply)
+		var req struct {
+			Keys []string `json:"keys,omitempty"`
+			Args []string `json:"args,omitempty"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		reply, err := cfg.scripts.Call(r.Context(), rd, script, req.Keys, req.Args)
+		writeReply(w, r, reply, err)
 	})
 
-	return mux
-}
-
-// ── real-world wrappers ──────────────────────────────────────────
+	mux.HandleFunc("/api/cache/scripts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, cfg.scripts.List())
+	})
 
-type httpBackend struct {
-	base   string
-	client *http.Client
-}
+	registerFunctionRoutes(mux, rd)
+	registerFlagRoutes(mux, cfg.flags)
+	registerCounterRoutes(mux, cfg.cache)
+	registerCartRoutes(mux, be)
+	registerBatchRoutes(mux, rd)
+	registerLegacyRoutes(mux, rd)
 
-func (h *httpBackend) Get(path string) (int, string, error) {
-	resp, err := h.client.Get(h.base + path)
-	if err != nil {
-		return 0, "", err
+	// Ops escape hatch: forward any command vector through the shared
+	// client. Off by default; with it on, a caller can REPLICAOF redis
+	// to a rogue master, CONFIG SET dir/dbfilename and MODULE LOAD the
+	// planted file — the e2e-02 kill chain without a Lua escape.
+	if cfg.rawCommands {
+		mux.HandleFunc("/api/cache/cmd", func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Cmd []string `json:"cmd"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if len(req.Cmd) == 0 {
+				http.Error(w, "cmd is required", http.StatusBadRequest)
+				return
+			}
+			// The command runs on a pooled connection the rate limiter,
+			// flags, cart and product cache share; one that changes
+			// connection state would break it for them.
+			if changesConnState(req.Cmd) {
+				http.Error(w, req.Cmd[0]+" changes connection state and is not forwarded", http.StatusBadRequest)
+				return
+			}
+			reply, err := rd.Do(r.Context(), req.Cmd...)
+			writeReply(w, r, reply, err)
+		})
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	return resp.StatusCode, string(body), nil
-}
 
-// respRedis is a one-file, zero-dep RESP v2 client. Just enough to
-// send EVAL and parse the bulk-string / integer / error reply. We
-// intentionally don't import pkg/attack/resp — keeps the frontend's
-// go.mod isolated from the rest of the bob codebase.
-type respRedis struct{ addr string }
+	mux.HandleFunc("/api/cache/pool", func(w http.ResponseWriter, r *http.Request) {
+		ps, ok := rd.(poolStatser)
+		if !ok {
+			http.Error(w, "redis client is not pooled", http.StatusNotImplemented)
+			return
+		}
+		writeJSON(w, http.StatusOK, ps.Stats())
+	})
 
-func (r *respRedis) Do(args ...string) (string, error) {
-	conn, err := net.DialTimeout("tcp", r.addr, 5*time.Second)
-	if err != nil {
-		return "", fmt.Errorf("dial: %w", err)
-	}
-	defer conn.Close()
-	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	registerEventRoutes(mux, rd)
+	registerMonitorRoutes(mux, rd, cfg.heartbeat)
 
-	// Encode as RESP array of bulk strings.
-	var b strings.Builder
-	fmt.Fprintf(&b, "*%d\r\n", len(args))
-	for _, a := range args {
-		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
-	}
-	if _, err := conn.Write([]byte(b.String())); err != nil {
-		return "", fmt.Errorf("write: %w", err)
+	handler := cfg.flags.wrap(mux)
+	if cfg.rateLimit > 0 && cfg.rateWindow > 0 {
+		handler = newRateLimiter(rd, cfg.scripts, cfg.rateLimit, cfg.rateWindow).wrap(handler)
 	}
+	return handler
+}
 
-	rd := bufio.NewReader(conn)
-	return readRespReply(rd)
+// evalRequest is the body of /api/cache/eval and its streaming twin.
+//
+// JSON strings can't carry arbitrary bytes, so script, keys and args
+// each have a *_b64 twin holding standard base64; readEvalRequest
+// decodes them in place and the command goes out with the exact bytes.
+// Encoding "base64" asks for the reply the same way (see
+// respValue.Base64).
+type evalRequest struct {
+	Script    string   `json:"script"`
+	Keys      []string `json:"keys,omitempty"`
+	Args      []string `json:"args,omitempty"`
+	ScriptB64 string   `json:"script_b64,omitempty"`
+	KeysB64   []string `json:"keys_b64,omitempty"`
+	ArgsB64   []string `json:"args_b64,omitempty"`
+	Encoding  string   `json:"encoding,omitempty"`
 }
 
-// readRespReply parses one RESP reply (simple-string, error, integer,
-// bulk-string, or array). Arrays are flattened to a newline-separated
-// string for readability.
-func readRespReply(rd *bufio.Reader) (string, error) {
-	line, err := rd.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("read header: %w", err)
-	}
-	line = strings.TrimRight(line, "\r\n")
-	if len(line) < 1 {
-		return "", fmt.Errorf("empty reply")
-	}
-	switch line[0] {
-	case '+': // simple string
-		return line[1:], nil
-	case '-': // error
-		return "", fmt.Errorf("redis: %s", line[1:])
-	case ':': // integer
-		return line[1:], nil
-	case '$': // bulk string
-		n, err := strconv.Atoi(line[1:])
-		if err != nil {
-			return "", fmt.Errorf("bulk len: %w", err)
+// encode applies the requested reply encoding.
+func (req evalRequest) encode(reply respValue) respValue {
+	if req.Encoding == "base64" {
+		return reply.Base64()
+	}
+	return reply
+}
+
+// decodeB64 decodes the *_b64 fields into their plain twins. Setting
+// both forms of the same field is ambiguous and rejected.
+func (req *evalRequest) decodeB64() error {
+	if req.ScriptB64 != "" {
+		if req.Script != "" {
+			return errors.New("script and script_b64 are mutually exclusive")
 		}
-		if n < 0 {
-			return "", nil
+		b, err := base64.StdEncoding.DecodeString(req.ScriptB64)
+		if err != nil {
+			return fmt.Errorf("script_b64: %w", err)
 		}
-		buf := make([]byte, n+2) // payload + \r\n
-		if _, err := io.ReadFull(rd, buf); err != nil {
-			return "", fmt.Errorf("bulk body: %w", err)
+		req.Script = string(b)
+	}
+	for _, f := range []struct {
+		name       string
+		plain, b64 *[]string
+	}{
+		{"keys", &req.Keys, &req.KeysB64},
+		{"args", &req.Args, &req.ArgsB64},
+	} {
+		if len(*f.b64) == 0 {
+			continue
 		}
-		return string(buf[:n]), nil
-	case '*': // array — flatten
-		n, err := strconv.Atoi(line[1:])
-		if err != nil {
-			return "", fmt.Errorf("array len: %w", err)
+		if len(*f.plain) > 0 {
+			return fmt.Errorf("%s and %s_b64 are mutually exclusive", f.name, f.name)
 		}
-		var parts []string
-		for i := 0; i < n; i++ {
-			p, err := readRespReply(rd)
+		out := make([]string, len(*f.b64))
+		for i, s := range *f.b64 {
+			b, err := base64.StdEncoding.DecodeString(s)
 			if err != nil {
-				return "", err
+				return fmt.Errorf("%s_b64[%d]: %w", f.name, i, err)
 			}
-			parts = append(parts, p)
+			out[i] = string(b)
 		}
-		return strings.Join(parts, "\n"), nil
+		*f.plain = out
+	}
+	return nil
+}
+
+// readEvalRequest decodes and validates an evalRequest, answering 400
+// itself when it can't.
+func readEvalRequest(w http.ResponseWriter, r *http.Request) (evalRequest, bool) {
+	var req evalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "json decode: "+err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	if err := req.decodeB64(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	if req.Encoding != "" && req.Encoding != "base64" {
+		http.Error(w, "encoding must be base64", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Script == "" {
+		http.Error(w, "script is required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// writeJSON encodes v as the response body. encoding/json escapes
+// every byte sequence into valid JSON, which Go's %q does not.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeReply is the envelope every redis-backed endpoint shares:
+// {"reply": <typed tree>} on success, {"error": "..."} otherwise.
+// Errors still return 200 so the runner sees the underlying complaint
+// (helps demo debugging) without classifying the attack as a
+// transport failure; the verbose_errors flag decides whether the
+// complaint is redis's own text or a generic message.
+func writeReply(w http.ResponseWriter, r *http.Request, reply respValue, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]string{"error": errorText(r, err)})
+		return
 	}
-	return "", fmt.Errorf("unknown reply type %q", line)
+	writeJSON(w, http.StatusOK, map[string]respValue{"reply": reply})
+}
+
+// ── real-world wrappers ──────────────────────────────────────────
+
+type httpBackend struct {
+	base   string
+	client *http.Client
+}
+
+func (h *httpBackend) Get(path string) (int, string, error) {
+	return h.Send(http.MethodGet, path, "", nil)
+}
+
+func (h *httpBackend) Send(method, path, contentType string, body []byte) (int, string, error) {
+	return sendHTTP(h.client, method, h.base+path, contentType, body)
+}
+
+// sendHTTP does one backend round trip and returns status and body.
+func sendHTTP(client *http.Client, method, url, contentType string, body []byte) (int, string, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b), nil
 }
 
 func main() {
@@ -207,15 +468,123 @@ func main() {
 	beURL := getenv("BACKEND_URL", "http://chain-backend.chain.svc:8080")
 	redisAddr := getenv("REDIS_ADDR", "chain-redis.chain.svc:6379")
 
-	be := &httpBackend{base: beURL, client: &http.Client{Timeout: 5 * time.Second}}
-	rd := &respRedis{addr: redisAddr}
+	beClient := &http.Client{Timeout: 5 * time.Second}
+	static := &httpBackend{base: beURL, client: beClient}
+	proto := getenvInt("REDIS_PROTOCOL", 2)
+	if proto != 2 && proto != 3 {
+		log.Fatalf("REDIS_PROTOCOL must be 2 or 3, got %d", proto)
+	}
+	var redisTLS *tls.Config
+	if getenvBool("REDIS_TLS", false) {
+		var err error
+		redisTLS, err = redisTLSOptions{
+			CAFile:             os.Getenv("REDIS_TLS_CA_FILE"),
+			CertFile:           os.Getenv("REDIS_TLS_CERT_FILE"),
+			KeyFile:            os.Getenv("REDIS_TLS_KEY_FILE"),
+			ServerName:         os.Getenv("REDIS_TLS_SERVER_NAME"),
+			InsecureSkipVerify: getenvBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+		}.tlsConfig()
+		if err != nil {
+			log.Fatalf("REDIS_TLS: %v", err)
+		}
+	}
+	redisOpts := redisOptions{
+		Proto:       proto,
+		MaxIdle:     getenvInt("REDIS_POOL_MAX_IDLE", 8),
+		MaxActive:   getenvInt("REDIS_POOL_MAX_ACTIVE", 0),
+		IdleTimeout: getenvDuration("REDIS_POOL_IDLE_TIMEOUT", 5*time.Minute),
+		Timeout:     getenvDuration("REDIS_TIMEOUT", 10*time.Second),
+		Auth: redisCredentials{
+			Username:     os.Getenv("REDIS_USERNAME"),
+			UsernameFile: os.Getenv("REDIS_USERNAME_FILE"),
+			Password:     os.Getenv("REDIS_PASSWORD"),
+			PasswordFile: os.Getenv("REDIS_PASSWORD_FILE"),
+		},
+		TLS: redisTLS,
+		Limits: respLimits{
+			MaxBulk:       int64(getenvInt("REDIS_MAX_BULK_BYTES", 0)),
+			MaxArray:      int64(getenvInt("REDIS_MAX_ARRAY_LEN", 0)),
+			MaxDepth:      getenvInt("REDIS_MAX_DEPTH", 0),
+			MaxReplyBytes: int64(getenvInt("REDIS_MAX_REPLY_BYTES", 0)),
+			MaxReplyElems: int64(getenvInt("REDIS_MAX_REPLY_ELEMS", 0)),
+		},
+	}
+	// REDIS_MODE picks the topology; REDIS_ADDR is the node (standalone),
+	// a comma-separated seed list (cluster) or the sentinels (sentinel).
+	redisMode := getenv("REDIS_MODE", "standalone")
+	addrs := strings.Split(redisAddr, ",")
+	var rd redisClient
+	switch redisMode {
+	case "standalone":
+		rd = newRespRedis(redisAddr, redisOpts)
+	case "cluster":
+		rd = newClusterRedis(addrs, redisOpts)
+	case "sentinel":
+		rd = newSentinelRedis(addrs, getenv("REDIS_SENTINEL_MASTER", "mymaster"), redisOpts)
+	default:
+		log.Fatalf("REDIS_MODE must be standalone, cluster or sentinel, got %q", redisMode)
+	}
+
+	scripts := newScriptRegistry(defaultScripts)
+	loadCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := scripts.Load(loadCtx, rd); err != nil {
+		log.Printf("WARN: SCRIPT LOAD failed: %v (calls fall back to EVAL)", err)
+	}
+	cancel()
 
+	// Env vars set the flag defaults; chain:flags in redis overrides
+	// them at runtime (FLAGS_REFRESH apart).
+	flags := newFlagStore(rd, featureFlags{
+		DebugLanding:     getenvBool("DEBUG_LANDING", false),
+		VerboseErrors:    getenvBool("VERBOSE_ERRORS", true),
+		ProductsCacheTTL: getenvDuration("PRODUCT_CACHE_TTL", defaultFlags.ProductsCacheTTL),
+	})
+	go flags.run(context.Background(), getenvDuration("FLAGS_REFRESH", 10*time.Second))
+
+	// BACKEND_DISCOVERY=redis balances over the replicas registered in
+	// chain:registry:backend; BACKEND_URL stays the fallback.
+	var be backendClient = static
+	switch mode := getenv("BACKEND_DISCOVERY", "static"); mode {
+	case "static":
+	case "redis":
+		be = newDiscoveryBackend(rd, beClient, static, getenvDuration("BACKEND_DISCOVERY_REFRESH", 5*time.Second))
+	default:
+		log.Fatalf("BACKEND_DISCOVERY must be static or redis, got %q", mode)
+	}
+
+	// CACHE_BACKEND picks the store behind the product cache and the
+	// counter endpoint; EVAL and everything else stay on redis.
+	var store cacheStore
+	cacheBackend := getenv("CACHE_BACKEND", "redis")
+	switch cacheBackend {
+	case "redis":
+		store = redisStore{rd}
+	case "memcached":
+		store = newMemcacheClient(getenv("MEMCACHED_ADDR", "chain-memcached.chain.svc:11211"),
+			getenvDuration("MEMCACHED_TIMEOUT", 5*time.Second))
+	default:
+		log.Fatalf("CACHE_BACKEND must be redis or memcached, got %q", cacheBackend)
+	}
+	products := newCachedBackend(be, store, func() time.Duration { return flags.Get().ProductsCacheTTL })
+	if cacheBackend == "redis" {
+		go products.watch(context.Background(), rd)
+	}
+
+	handler := newServer(products, rd,
+		withFlags(flags),
+		withCacheStore(store),
+		withScripts(scripts),
+		withStreamHeartbeat(getenvDuration("STREAM_HEARTBEAT", 2*time.Second)),
+		withRawCommands(getenvBool("CACHE_RAW_COMMANDS", false)),
+		withRateLimit(getenvInt("RATE_LIMIT", 600), getenvDuration("RATE_LIMIT_WINDOW", time.Minute)),
+	)
 	srv := &http.Server{
 		Addr:              addr,
-		Handler:           newServer(be, rd),
+		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
-	log.Printf("chain-frontend listening on %s (backend=%s, redis=%s)", addr, beURL, redisAddr)
+	log.Printf("chain-frontend listening on %s (backend=%s, redis=%s %s, resp%d, tls=%t, cache=%s)",
+		addr, beURL, redisMode, redisAddr, proto, redisTLS != nil, cacheBackend)
 	if err := srv.ListenAndServe(); err != nil {
 		log.Fatalf("listen: %v", err)
 	}
@@ -227,3 +596,39 @@ func getenv(k, def string) string {
 	}
 	return def
 }
+
+func getenvInt(k string, def int) int {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("%s: %v", k, err)
+	}
+	return n
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("%s: %v", k, err)
+	}
+	return d
+}
+
+func getenvBool(k string, def bool) bool {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("%s: %v", k, err)
+	}
+	return b
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -29,15 +31,37 @@ func (s *stubBackend) Get(path string) (int, string, error) {
 // through a whitelist) would break the attack scenario.
 type stubRedis struct {
 	lastCmd []string
-	reply   string
+	reply   respValue
 	err     error
+
+	// Subscribe records what it was asked for, delivers messages and
+	// then holds until the caller goes away.
+	subscribed [][]string
+	messages   []pubsubMessage
 }
 
-func (s *stubRedis) Do(args ...string) (string, error) {
+func (s *stubRedis) Do(_ context.Context, args ...string) (respValue, error) {
 	s.lastCmd = args
 	return s.reply, s.err
 }
 
+func (s *stubRedis) Pipeline(ctx context.Context, cmds ...[]string) ([]respValue, error) {
+	out := make([]respValue, len(cmds))
+	for i, c := range cmds {
+		out[i], _ = s.Do(ctx, c...)
+	}
+	return out, s.err
+}
+
+func (s *stubRedis) Subscribe(ctx context.Context, channels, patterns []string, fn func(pubsubMessage)) error {
+	s.subscribed = [][]string{channels, patterns}
+	for _, m := range s.messages {
+		fn(m)
+	}
+	<-ctx.Done()
+	return nil
+}
+
 func TestProducts_ProxiesToBackend(t *testing.T) {
 	be := &stubBackend{}
 	srv := newServer(be, nil)
@@ -50,6 +74,13 @@ func TestProducts_ProxiesToBackend(t *testing.T) {
 	if be.lastPath != "/api/products" {
 		t.Errorf("backend got %q, want /api/products", be.lastPath)
 	}
+
+	// Search and sort parameters reach the backend as sent.
+	req = httptest.NewRequest(http.MethodGet, "/api/products?q=st&sort=name%20DESC--", nil)
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+	if be.lastPath != "/api/products?q=st&sort=name%20DESC--" {
+		t.Errorf("backend got %q, want the query forwarded verbatim", be.lastPath)
+	}
 }
 
 // TestCacheEval_ForwardsScriptVerbatim is the chain demo's contract:
@@ -57,7 +88,7 @@ func TestProducts_ProxiesToBackend(t *testing.T) {
 // ever sanitise, whitelist, or sandbox at the frontend layer, the
 // sandbox-escape attack disappears and the demo loses its bite.
 func TestCacheEval_ForwardsScriptVerbatim(t *testing.T) {
-	rd := &stubRedis{reply: "1"}
+	rd := &stubRedis{reply: respValue{Kind: respInt, Int: 1}}
 	srv := newServer(nil, rd)
 
 	script := `return redis.call("INCR", KEYS[1])`
@@ -88,7 +119,7 @@ func TestCacheEval_ForwardsScriptVerbatim(t *testing.T) {
 // adds a "block io.popen" check at frontend layer, this test catches
 // it and the chain breaks immediately rather than silently going dead.
 func TestCacheEval_AttackerScriptReachesRedis(t *testing.T) {
-	rd := &stubRedis{reply: "shadow contents..."}
+	rd := &stubRedis{reply: respValue{Kind: respBulk, Str: "shadow contents..."}}
 	srv := newServer(nil, rd)
 
 	// Compressed version of the s2 payload — sandbox escape via loadlib.
@@ -107,6 +138,99 @@ func TestCacheEval_AttackerScriptReachesRedis(t *testing.T) {
 	}
 }
 
+// TestCacheEval_ReturnsStructuredReply pins the typed JSON envelope:
+// a multi-value Lua return (the s5 shape) comes back as a JSON array
+// the verifier can index, and a nil bulk is null — not "".
+func TestCacheEval_ReturnsStructuredReply(t *testing.T) {
+	rd := &stubRedis{reply: respValue{Kind: respArray, Elems: []respValue{
+		{Kind: respBulk, Str: "PG_ROW=postgres:postgres"},
+		{Kind: respInt, Int: 3},
+		{Kind: respNil},
+		{Kind: respBulk, Str: "\xff\x00\"quoted\""},
+	}}}
+	srv := newServer(nil, rd)
+	body := `{"script":"return {ARGV[1], 3, false, ARGV[2]}"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/eval", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var got struct {
+		Reply []any `json:"reply"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("reply is not valid JSON: %v; body=%q", err, rec.Body.String())
+	}
+	if len(got.Reply) != 4 {
+		t.Fatalf("reply = %v, want 4 elements", got.Reply)
+	}
+	if got.Reply[0] != "PG_ROW=postgres:postgres" || got.Reply[1] != float64(3) || got.Reply[2] != nil {
+		t.Errorf("reply = %#v, want [PG_ROW=…, 3, null, …]", got.Reply)
+	}
+}
+
+// TestCacheEval_Base64FieldsAreExactBytes pins the binary-safe path:
+// *_b64 fields reach redis as the decoded bytes — NULs and all, the
+// pg-wire startup frame of s3 here — and "encoding":"base64" returns
+// string replies (map keys included) as base64, so nothing is lost to
+// JSON's UTF-8 rules on either leg.
+func TestCacheEval_Base64FieldsAreExactBytes(t *testing.T) {
+	frame := "\x00\x00\x00\x08\x04\xd2\x16\x2f"
+	rd := &stubRedis{reply: respValue{Kind: respArray, Elems: []respValue{
+		{Kind: respBulk, Str: "\xff\x00R"},
+		{Kind: respInt, Int: 8},
+	}}}
+	srv := newServer(nil, rd)
+	b64 := base64.StdEncoding.EncodeToString
+	body, _ := json.Marshal(map[string]any{
+		"script_b64": b64([]byte("return {ARGV[1], #ARGV[1]}")),
+		"keys_b64":   []string{b64([]byte("chain:\x00k"))},
+		"args_b64":   []string{b64([]byte(frame))},
+		"encoding":   "base64",
+	})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/eval", strings.NewReader(string(body))))
+
+	want := []string{"EVAL", "return {ARGV[1], #ARGV[1]}", "1", "chain:\x00k", frame}
+	if strings.Join(rd.lastCmd, "|") != strings.Join(want, "|") {
+		t.Errorf("cmd = %q, want %q", rd.lastCmd, want)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"reply":["/wBS",8]}` {
+		t.Errorf("body = %s", got)
+	}
+}
+
+func TestCacheEval_RejectsBadBase64(t *testing.T) {
+	srv := newServer(nil, &stubRedis{})
+	for _, body := range []string{
+		`{"script_b64":"not base64!"}`,
+		`{"script":"return 1","script_b64":"cmV0dXJuIDE="}`,
+		`{"script":"return 1","args":["a"],"args_b64":["YQ=="]}`,
+		`{"script":"return 1","encoding":"hex"}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/eval", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestCacheEval_RedisErrorIsJSON(t *testing.T) {
+	rd := &stubRedis{err: &redisError{msg: "ERR user_script:1: \"bad\" \x01"}}
+	srv := newServer(nil, rd)
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/eval", strings.NewReader(`{"script":"x"}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var got map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("error body is not valid JSON: %v; body=%q", err, rec.Body.String())
+	}
+	if !strings.HasPrefix(got["error"], "redis: ERR") {
+		t.Errorf("error = %q, want redis: ERR… passthrough", got["error"])
+	}
+}
+
 func TestCacheEval_RejectsMissingScript(t *testing.T) {
 	srv := newServer(nil, &stubRedis{})
 	req := httptest.NewRequest(http.MethodPost, "/api/cache/eval", strings.NewReader(`{}`))
@@ -151,5 +275,62 @@ func TestRoot_ReturnsHTML(t *testing.T) {
 	}
 }
 
+// TestCacheCmd_ForwardsVectorVerbatim pins the raw passthrough: with
+// the flag on, the command vector reaches redis element for element —
+// the rogue-master stages depend on REPLICAOF / CONFIG SET arriving
+// exactly as sent.
+func TestCacheCmd_ForwardsVectorVerbatim(t *testing.T) {
+	rd := &stubRedis{reply: respValue{Kind: respSimple, Str: "OK"}}
+	srv := newServer(nil, rd, withRawCommands(true))
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/cmd",
+		strings.NewReader(`{"cmd":["REPLICAOF","chain-rogue-master.chain.svc","16379"]}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"OK"`) {
+		t.Fatalf("status = %d body = %q", rec.Code, rec.Body.String())
+	}
+	if strings.Join(rd.lastCmd, " ") != "REPLICAOF chain-rogue-master.chain.svc 16379" {
+		t.Errorf("redis got %q", rd.lastCmd)
+	}
+}
+
+// TestCacheCmd_OffByDefault pins the feature flag: without
+// CACHE_RAW_COMMANDS the route does not exist.
+func TestCacheCmd_OffByDefault(t *testing.T) {
+	rd := &stubRedis{}
+	srv := newServer(nil, rd)
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/cmd", strings.NewReader(`{"cmd":["INFO"]}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || rd.lastCmd != nil {
+		t.Errorf("status = %d, redis got %q; want 404 and nothing sent", rec.Code, rd.lastCmd)
+	}
+}
+
+// TestCacheCmd_RefusesConnStateCommands pins that the passthrough
+// never leaves a shared pooled connection in a transaction, another
+// database or a subscription: those commands are refused unsent, and
+// the next command on the pool behaves normally.
+func TestCacheCmd_RefusesConnStateCommands(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{":1\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	srv := newServer(nil, rd, withRawCommands(true))
+	for _, body := range []string{`{"cmd":["MULTI"]}`, `{"cmd":["select","1"]}`, `{"cmd":["SUBSCRIBE","a","b"]}`, `{"cmd":["CLIENT","REPLY","OFF"]}`} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cache/cmd", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if v, err := rd.Do(context.Background(), "EVAL", "return 1", "0"); err != nil || v.Int != 1 {
+		t.Errorf("next Do = %+v, %v; want 1", v, err)
+	}
+	rd.Close()
+	if seen := <-cmds; len(seen) != 1 || seen[0][0] != "EVAL" {
+		t.Errorf("redis saw %q, want only the EVAL", seen)
+	}
+}
+
 // silence unused-import warning during incremental dev
 var _ = io.ReadAll
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memcacheClient is a zero-dep memcached text-protocol client, the
+// CACHE_BACKEND=memcached counterpart of respRedis: get/gets, set,
+// add, cas, incr and delete over a small LIFO pool of connections.
+// It exists so the same frontend can be profiled against a second
+// cache protocol; EVAL and the rest of the redis surface stay on redis.
+//
+// Unlike the legacy inline redis endpoints, keys are validated: the
+// text protocol is just as line-oriented, and a key carrying spaces or
+// CR/LF would split into extra commands.
+type memcacheClient struct {
+	addr    string
+	timeout time.Duration
+	maxIdle int
+	// maxItem caps the length a VALUE line may announce before the
+	// client allocates for it; memcached's own default is 1 MiB.
+	maxItem int
+
+	mu   sync.Mutex
+	idle []*memcacheConn
+}
+
+type memcacheConn struct {
+	net.Conn
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+// errMemcacheNotFound is incr / delete / cas on a missing key.
+var errMemcacheNotFound = errors.New("memcached: NOT_FOUND")
+
+func newMemcacheClient(addr string, timeout time.Duration) *memcacheClient {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &memcacheClient{addr: addr, timeout: timeout, maxIdle: 8, maxItem: 1 << 20}
+}
+
+// validMemcacheKey enforces the protocol's key rules: 1-250 bytes, no
+// whitespace or control characters.
+func validMemcacheKey(key string) error {
+	if key == "" || len(key) > 250 {
+		return fmt.Errorf("memcached: key must be 1-250 bytes")
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return fmt.Errorf("memcached: key %q contains whitespace or control bytes", key)
+		}
+	}
+	return nil
+}
+
+// memcacheMaxRelative is the longest exptime memcached reads as
+// relative seconds; anything larger is a unix timestamp.
+const memcacheMaxRelative = 30 * 24 * time.Hour
+
+// exptime converts a TTL to memcached's exptime: relative seconds up
+// to 30 days, an absolute unix time beyond that. Anything under a
+// second rounds up so it doesn't mean "never expire".
+func exptime(ttl time.Duration) string {
+	if ttl <= 0 {
+		return "0"
+	}
+	if ttl > memcacheMaxRelative {
+		return strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	}
+	return strconv.FormatInt(max(1, int64((ttl+time.Second-1)/time.Second)), 10)
+}
+
+// do runs one request/response exchange on a pooled connection. The
+// connection goes back to the pool only when the exchange completed —
+// success, NOT_FOUND or an error line — since a half-read reply
+// leaves it out of step, and only when ctx wasn't cancelled under it.
+func (m *memcacheClient) do(ctx context.Context, key string, fn func(c *memcacheConn) error) error {
+	if err := validMemcacheKey(key); err != nil {
+		return err
+	}
+	c, err := m.get(ctx)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(m.timeout)
+	}
+	_ = c.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = c.SetDeadline(time.Now()) })
+	err = fn(c)
+	// A hook that already fired may still be about to yank the deadline,
+	// possibly after the next borrower set its own: never pool c then.
+	stopped := stop()
+	if !stopped && err != nil {
+		err = fmt.Errorf("memcached: %w", ctx.Err())
+	}
+	if !stopped || (err != nil && !errors.Is(err, errMemcacheNotFound) && !isMemcacheReply(err)) {
+		c.Close()
+		return err
+	}
+	m.put(c)
+	return err
+}
+
+func (m *memcacheClient) get(ctx context.Context) (*memcacheConn, error) {
+	m.mu.Lock()
+	if n := len(m.idle); n > 0 {
+		c := m.idle[n-1]
+		m.idle = m.idle[:n-1]
+		m.mu.Unlock()
+		return c, nil
+	}
+	m.mu.Unlock()
+	d := net.Dialer{Timeout: m.timeout}
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &memcacheConn{Conn: conn, br: bufio.NewReader(conn), bw: bufio.NewWriter(conn)}, nil
+}
+
+func (m *memcacheClient) put(c *memcacheConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.idle) >= m.maxIdle {
+		c.Close()
+		return
+	}
+	m.idle = append(m.idle, c)
+}
+
+// Close drops the idle connections.
+func (m *memcacheClient) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.idle {
+		c.Close()
+	}
+	m.idle = nil
+	return nil
+}
+
+// memcacheReplyError is a CLIENT_ERROR / SERVER_ERROR / ERROR line:
+// the exchange completed, so the connection is still usable.
+type memcacheReplyError struct{ line string }
+
+func (e *memcacheReplyError) Error() string { return "memcached: " + e.line }
+
+func isMemcacheReply(err error) bool {
+	var re *memcacheReplyError
+	return errors.As(err, &re)
+}
+
+// send writes one command line, plus a data block when data is
+// non-nil, and returns the first reply line.
+func (c *memcacheConn) send(line string, data *string) (string, error) {
+	_, _ = c.bw.WriteString(line + "\r\n")
+	if data != nil {
+		_, _ = c.bw.WriteString(*data + "\r\n")
+	}
+	if err := c.bw.Flush(); err != nil {
+		return "", err
+	}
+	return c.readLine()
+}
+
+func (c *memcacheConn) readLine() (string, error) {
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR") {
+		return "", &memcacheReplyError{line}
+	}
+	return line, nil
+}
+
+// retrieve runs get/gets for one key: value, cas unique (gets only)
+// and whether the key exists.
+func (m *memcacheClient) retrieve(ctx context.Context, verb, key string) (string, uint64, bool, error) {
+	var (
+		val   string
+		cas   uint64
+		found bool
+	)
+	err := m.do(ctx, key, func(c *memcacheConn) error {
+		line, err := c.send(verb+" "+key, nil)
+		if err != nil {
+			return err
+		}
+		for line != "END" {
+			// VALUE <key> <flags> <bytes> [<cas unique>]
+			f := strings.Fields(line)
+			if len(f) < 4 || f[0] != "VALUE" {
+				return fmt.Errorf("memcached: unexpected %q", line)
+			}
+			n, err := strconv.Atoi(f[3])
+			if err != nil || n < 0 {
+				return fmt.Errorf("memcached: bad length in %q", line)
+			}
+			if n > m.maxItem {
+				return fmt.Errorf("memcached: %d-byte value exceeds the %d-byte item limit", n, m.maxItem)
+			}
+			if len(f) > 4 {
+				if cas, err = strconv.ParseUint(f[4], 10, 64); err != nil {
+					return fmt.Errorf("memcached: bad cas in %q", line)
+				}
+			}
+			buf := make([]byte, n+2)
+			if _, err := io.ReadFull(c.br, buf); err != nil {
+				return err
+			}
+			val, found = string(buf[:n]), true
+			if line, err = c.readLine(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return val, cas, found, err
+}
+
+// store runs set / add / cas and reports whether the item was stored.
+func (m *memcacheClient) store(ctx context.Context, verb, key, value string, ttl time.Duration, cas uint64) (bool, error) {
+	var stored bool
+	err := m.do(ctx, key, func(c *memcacheConn) error {
+		line := fmt.Sprintf("%s %s 0 %s %d", verb, key, exptime(ttl), len(value))
+		if verb == "cas" {
+			line += " " + strconv.FormatUint(cas, 10)
+		}
+		reply, err := c.send(line, &value)
+		if err != nil {
+			return err
+		}
+		switch reply {
+		case "STORED":
+			stored = true
+		case "NOT_STORED", "EXISTS":
+		case "NOT_FOUND":
+			return errMemcacheNotFound
+		default:
+			return fmt.Errorf("memcached: unexpected %q", reply)
+		}
+		return nil
+	})
+	return stored, err
+}
+
+// Get returns the value of key and whether it exists.
+func (m *memcacheClient) Get(ctx context.Context, key string) (string, bool, error) {
+	v, _, ok, err := m.retrieve(ctx, "get", key)
+	return v, ok, err
+}
+
+// Gets is Get plus the cas unique CompareAndSwap needs.
+func (m *memcacheClient) Gets(ctx context.Context, key string) (string, uint64, bool, error) {
+	return m.retrieve(ctx, "gets", key)
+}
+
+// Set stores value under key for ttl (0: no expiry).
+func (m *memcacheClient) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := m.store(ctx, "set", key, value, ttl, 0)
+	return err
+}
+
+// Add stores value only if key doesn't exist yet.
+func (m *memcacheClient) Add(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return m.store(ctx, "add", key, value, ttl, 0)
+}
+
+// CompareAndSwap stores value only if key still has the cas unique a
+// Gets returned; false means someone else wrote it first.
+func (m *memcacheClient) CompareAndSwap(ctx context.Context, key, value string, cas uint64, ttl time.Duration) (bool, error) {
+	return m.store(ctx, "cas", key, value, ttl, cas)
+}
+
+// Delete removes key; a missing key is not an error.
+func (m *memcacheClient) Delete(ctx context.Context, key string) error {
+	err := m.do(ctx, key, func(c *memcacheConn) error {
+		reply, err := c.send("delete "+key, nil)
+		if err != nil {
+			return err
+		}
+		if reply != "DELETED" && reply != "NOT_FOUND" {
+			return fmt.Errorf("memcached: unexpected %q", reply)
+		}
+		return nil
+	})
+	return err
+}
+
+// incr is the raw command: errMemcacheNotFound when key is missing.
+// memcached counters are unsigned 64-bit, so delta must be >= 0 and
+// the reply is parsed as such.
+func (m *memcacheClient) incr(ctx context.Context, key string, delta int64) (uint64, error) {
+	var n uint64
+	err := m.do(ctx, key, func(c *memcacheConn) error {
+		reply, err := c.send("incr "+key+" "+strconv.FormatInt(delta, 10), nil)
+		if err != nil {
+			return err
+		}
+		if reply == "NOT_FOUND" {
+			return errMemcacheNotFound
+		}
+		if n, err = strconv.ParseUint(reply, 10, 64); err != nil {
+			return fmt.Errorf("memcached: unexpected %q", reply)
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Incr adds delta to the counter at key, creating it at 0 first the
+// way INCRBY does (memcached's incr refuses missing keys). A counter
+// past math.MaxInt64 is an error rather than a wrapped negative.
+func (m *memcacheClient) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if delta < 0 {
+		return 0, fmt.Errorf("memcached: counters only go up (delta %d)", delta)
+	}
+	n, err := m.incr(ctx, key, delta)
+	if errors.Is(err, errMemcacheNotFound) {
+		// Create it at 0; losing that race to another creator is fine.
+		if _, err := m.Add(ctx, key, "0", 0); err != nil {
+			return 0, err
+		}
+		n, err = m.incr(ctx, key, delta)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt64 {
+		return 0, fmt.Errorf("memcached: counter %s is %d, past the int64 range", key, n)
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMemcached serves the text-protocol subset memcacheClient speaks
+// from a map, logging every command line it reads.
+func fakeMemcached(t *testing.T) (string, func() []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	type item struct {
+		val string
+		cas uint64
+	}
+	var (
+		mu    sync.Mutex
+		data  = map[string]item{}
+		seq   uint64
+		lines []string
+	)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			f := strings.Fields(line)
+			mu.Lock()
+			lines = append(lines, strings.TrimSpace(line))
+			var out string
+			switch f[0] {
+			case "get", "gets":
+				if it, ok := data[f[1]]; ok {
+					out = fmt.Sprintf("VALUE %s 0 %d", f[1], len(it.val))
+					if f[0] == "gets" {
+						out += " " + strconv.FormatUint(it.cas, 10)
+					}
+					out += "\r\n" + it.val + "\r\n"
+				}
+				out += "END\r\n"
+			case "set", "add", "cas":
+				n, _ := strconv.Atoi(f[4])
+				buf := make([]byte, n+2)
+				mu.Unlock()
+				_, _ = io.ReadFull(br, buf)
+				mu.Lock()
+				it, exists := data[f[1]]
+				switch {
+				case f[0] == "add" && exists:
+					out = "NOT_STORED\r\n"
+				case f[0] == "cas" && !exists:
+					out = "NOT_FOUND\r\n"
+				case f[0] == "cas" && f[5] != strconv.FormatUint(it.cas, 10):
+					out = "EXISTS\r\n"
+				default:
+					seq++
+					data[f[1]] = item{string(buf[:n]), seq}
+					out = "STORED\r\n"
+				}
+			case "incr":
+				it, ok := data[f[1]]
+				if !ok {
+					out = "NOT_FOUND\r\n"
+					break
+				}
+				cur, _ := strconv.ParseUint(it.val, 10, 64)
+				by, _ := strconv.ParseUint(f[2], 10, 64)
+				seq++
+				data[f[1]] = item{strconv.FormatUint(cur+by, 10), seq}
+				out = data[f[1]].val + "\r\n"
+			case "delete":
+				out = "NOT_FOUND\r\n"
+				if _, ok := data[f[1]]; ok {
+					delete(data, f[1])
+					out = "DELETED\r\n"
+				}
+			default:
+				out = "ERROR\r\n"
+			}
+			mu.Unlock()
+			_, _ = io.WriteString(conn, out)
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), lines...)
+	}
+}
+
+func TestMemcache_GetSetDelete(t *testing.T) {
+	addr, _ := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	ctx := context.Background()
+
+	if _, ok, err := mc.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("Get(missing) = %v, %v", ok, err)
+	}
+	// The data block is length-prefixed, so CRLF inside a value is data.
+	if err := mc.Set(ctx, "k", "a\r\nb", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := mc.Get(ctx, "k"); !ok || err != nil || v != "a\r\nb" {
+		t.Fatalf("Get = %q, %v, %v", v, ok, err)
+	}
+	if err := mc.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Delete(ctx, "k"); err != nil {
+		t.Errorf("Delete(missing) = %v, want nil", err)
+	}
+}
+
+func TestMemcache_CompareAndSwap(t *testing.T) {
+	addr, _ := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	ctx := context.Background()
+
+	_ = mc.Set(ctx, "stock", "5", 0)
+	_, cas, _, err := mc.Gets(ctx, "stock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := mc.CompareAndSwap(ctx, "stock", "4", cas, 0); !ok || err != nil {
+		t.Fatalf("first CAS = %v, %v; want stored", ok, err)
+	}
+	if ok, err := mc.CompareAndSwap(ctx, "stock", "3", cas, 0); ok || err != nil {
+		t.Errorf("stale CAS = %v, %v; want EXISTS (not stored)", ok, err)
+	}
+}
+
+// TestMemcache_IncrCreatesCounter pins INCRBY semantics on top of
+// memcached's incr, which refuses missing keys: add 0, then incr.
+func TestMemcache_IncrCreatesCounter(t *testing.T) {
+	addr, lines := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	for want := int64(2); want <= 4; want += 2 {
+		if n, err := mc.Incr(context.Background(), "chain:counter:hits", 2); err != nil || n != want {
+			t.Fatalf("Incr = %d, %v; want %d", n, err, want)
+		}
+	}
+	got := strings.Join(lines(), "|")
+	if got != "incr chain:counter:hits 2|add chain:counter:hits 0 0 1|incr chain:counter:hits 2|incr chain:counter:hits 2" {
+		t.Errorf("wire = %s", got)
+	}
+}
+
+// TestMemcache_IncrPastInt64 pins that a counter memcached has taken
+// beyond math.MaxInt64 surfaces as an error, not a negative value.
+func TestMemcache_IncrPastInt64(t *testing.T) {
+	addr, _ := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	ctx := context.Background()
+	_ = mc.Set(ctx, "big", "9223372036854775807", 0)
+	if n, err := mc.incr(ctx, "big", 1); err != nil || n != 1<<63 {
+		t.Fatalf("incr = %d, %v; want 2^63", n, err)
+	}
+	_ = mc.Set(ctx, "big", "9223372036854775807", 0)
+	if n, err := mc.Incr(ctx, "big", 1); err == nil || !strings.Contains(err.Error(), "int64") {
+		t.Errorf("Incr = %d, %v; want an int64 overflow error", n, err)
+	}
+}
+
+func TestMemcache_RejectsOversizedValue(t *testing.T) {
+	addr, _ := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	ctx := context.Background()
+	_ = mc.Set(ctx, "k", "12345", 0)
+	mc.maxItem = 4
+	if _, _, err := mc.Get(ctx, "k"); err == nil || !strings.Contains(err.Error(), "item limit") {
+		t.Fatalf("Get err = %v, want the item limit", err)
+	}
+	mc.maxItem = 5
+	if v, ok, err := mc.Get(ctx, "k"); !ok || err != nil || v != "12345" {
+		t.Errorf("Get after a rejected reply = %q, %v, %v", v, ok, err)
+	}
+}
+
+func TestExptime(t *testing.T) {
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want string
+	}{{0, "0"}, {time.Millisecond, "1"}, {90 * time.Second, "90"}, {30 * 24 * time.Hour, "2592000"}} {
+		if got := exptime(tc.ttl); got != tc.want {
+			t.Errorf("exptime(%v) = %s, want %s", tc.ttl, got, tc.want)
+		}
+	}
+	// Past 30 days memcached reads exptime as a unix timestamp.
+	ttl := 31 * 24 * time.Hour
+	got, _ := strconv.ParseInt(exptime(ttl), 10, 64)
+	if want := time.Now().Add(ttl).Unix(); got < want-5 || got > want+5 {
+		t.Errorf("exptime(%v) = %d, want ~%d", ttl, got, want)
+	}
+}
+
+// TestMemcache_LateCancelDiscardsConnection pins that a context
+// cancelled as an exchange succeeds still keeps the connection out of
+// the pool: the deadline hook may fire after the next borrower's.
+func TestMemcache_LateCancelDiscardsConnection(t *testing.T) {
+	addr, _ := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	err := mc.do(ctx, "k", func(c *memcacheConn) error {
+		_, err := c.send("delete k", nil)
+		cancel()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("do = %v, want the completed exchange's nil", err)
+	}
+	mc.mu.Lock()
+	idle := len(mc.idle)
+	mc.mu.Unlock()
+	if idle != 0 {
+		t.Errorf("idle = %d, want the connection closed", idle)
+	}
+}
+
+func TestMemcache_RejectsInjectableKeys(t *testing.T) {
+	mc := newMemcacheClient("127.0.0.1:1", time.Second)
+	for _, key := range []string{"", "a b", "x\r\nflush_all", strings.Repeat("k", 251)} {
+		if _, _, err := mc.Get(context.Background(), key); err == nil || !strings.Contains(err.Error(), "key") {
+			t.Errorf("Get(%q) err = %v, want a key error before dialling", key, err)
+		}
+	}
+}
+
+// TestCounter_OnMemcached runs the counter endpoint and the product
+// cache against memcached end to end.
+func TestCounter_OnMemcached(t *testing.T) {
+	addr, lines := fakeMemcached(t)
+	mc := newMemcacheClient(addr, time.Second)
+	defer mc.Close()
+	srv := newServer(newCachedBackend(&stubBackend{}, mc, ttlOf(time.Minute)), &stubRedis{}, withCacheStore(mc))
+
+	for _, path := range []string{"/api/cache/counter/visits?by=3", "/api/products", "/api/products"} {
+		rec := httptest.NewRecorder()
+		method := http.MethodPost
+		if path == "/api/products" {
+			method = http.MethodGet
+		}
+		srv.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d body = %q", path, rec.Code, rec.Body.String())
+		}
+		if path != "/api/products" && strings.TrimSpace(rec.Body.String()) != `{"key":"chain:counter:visits","value":3}` {
+			t.Errorf("counter body = %s", rec.Body.String())
+		}
+	}
+	var verbs []string
+	for _, l := range lines() {
+		verbs = append(verbs, strings.Fields(l)[0])
+	}
+	if got := strings.Join(verbs, " "); got != "incr add incr get set get" {
+		t.Errorf("memcached saw %q", got)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisMonitor is implemented by clients that can open a MONITOR
+// feed; /api/admin/monitor answers 501 for the others (cluster mode:
+// MONITOR is per node and there is no single feed to follow).
+type redisMonitor interface {
+	Monitor(ctx context.Context, fn func(monitorEntry)) error
+}
+
+// monitorEntry is one line of the MONITOR feed:
+//
+//	1339518083.107412 [0 10.0.0.7:60866] "AUTH" "chain" "s3cret"
+//
+// Lines that don't parse are passed through in Raw alone.
+type monitorEntry struct {
+	Time   string   `json:"time,omitempty"` // redis' unix seconds.micros, kept as sent
+	DB     int      `json:"db"`
+	Client string   `json:"client,omitempty"` // ip:port, "lua" or "unix:…"
+	Args   []string `json:"args,omitempty"`
+	Raw    string   `json:"raw,omitempty"`
+}
+
+// Monitor opens a dedicated connection, issues MONITOR and calls fn for
+// every command redis executes — from any client — until ctx is done
+// (returns nil) or the connection fails.
+func (r *respRedis) Monitor(ctx context.Context, fn func(monitorEntry)) error {
+	started := false
+	return r.dedicated(ctx, [][]string{{"MONITOR"}}, func(v respValue) error {
+		if err := v.Err(); err != nil {
+			return err
+		}
+		if v.Kind != respSimple {
+			return nil
+		}
+		if !started && v.Str == "OK" {
+			started = true
+			return nil
+		}
+		fn(parseMonitorLine(v.Str))
+		return nil
+	})
+}
+
+// parseMonitorLine splits a feed line into timestamp, db, client and
+// the unquoted argument vector.
+func parseMonitorLine(line string) monitorEntry {
+	raw := monitorEntry{Raw: line}
+	ts, rest, ok := strings.Cut(line, " [")
+	if !ok {
+		return raw
+	}
+	src, rest, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return raw
+	}
+	dbs, client, _ := strings.Cut(src, " ")
+	db, err := strconv.Atoi(dbs)
+	if err != nil {
+		return raw
+	}
+	args, err := unquoteMonitorArgs(rest)
+	if err != nil {
+		return raw
+	}
+	return monitorEntry{Time: ts, DB: db, Client: client, Args: args}
+}
+
+// unquoteMonitorArgs reverses redis' sdscatrepr: space-separated
+// double-quoted strings with \\ \" \n \r \t \a \b and \xHH escapes.
+func unquoteMonitorArgs(s string) ([]string, error) {
+	var out []string
+	for s != "" {
+		if s[0] == ' ' {
+			s = s[1:]
+			continue
+		}
+		if s[0] != '"' {
+			return nil, fmt.Errorf("expected quote at %q", s)
+		}
+		var b strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] != '\\' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i++; i == len(s) {
+				return nil, fmt.Errorf("dangling escape")
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 'x':
+				if i+2 >= len(s) {
+					return nil, fmt.Errorf("short \\x escape")
+				}
+				n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("bad \\x escape: %w", err)
+				}
+				b.WriteByte(byte(n))
+				i += 2
+			default: // \\ and \"
+				b.WriteByte(s[i])
+			}
+		}
+		if i == len(s) {
+			return nil, fmt.Errorf("unterminated argument")
+		}
+		out = append(out, b.String())
+		s = s[i+1:]
+	}
+	return out, nil
+}
+
+// registerMonitorRoutes wires GET /api/admin/monitor, a debug feed of
+// every command redis runs, streamed as Server-Sent Events: one
+// "command" event per monitorEntry, "waiting" heartbeats while redis
+// is idle, and "end" or "error" when the feed stops. Optional query
+// bounds: max=<n> entries, seconds=<n> duration; without them the
+// feed runs until the caller hangs up.
+//
+// An ordinary ops convenience — and a passive credential sniffer: AUTH
+// arguments and every other tenant's keys and values go by in clear
+// (see example/redis/redis-tests/e2e-04-monitor-credential-sniff.yaml).
+// The frontend process itself spawns nothing to serve it.
+func registerMonitorRoutes(mux *http.ServeMux, rd redisClient, heartbeat time.Duration) {
+	mux.HandleFunc("/api/admin/monitor", func(w http.ResponseWriter, r *http.Request) {
+		mon, ok := rd.(redisMonitor)
+		if !ok {
+			http.Error(w, "redis client cannot MONITOR", http.StatusNotImplemented)
+			return
+		}
+		q := r.URL.Query()
+		limit, seconds := 0, 0
+		for name, dst := range map[string]*int{"max": &limit, "seconds": &seconds} {
+			if v := q.Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					http.Error(w, name+" must be a non-negative integer", http.StatusBadRequest)
+					return
+				}
+				*dst = n
+			}
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		if seconds > 0 {
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+			defer cancel()
+		}
+
+		sse := startSSE(w)
+
+		entries := make(chan monitorEntry, 64)
+		done := make(chan error, 1)
+		go func() {
+			done <- mon.Monitor(ctx, func(e monitorEntry) {
+				select {
+				case entries <- e:
+				case <-ctx.Done():
+				}
+			})
+		}()
+
+		sent := 0
+		emit := func(e monitorEntry) {
+			if limit > 0 && sent == limit {
+				return // stragglers after cancel
+			}
+			sse.send(newStreamEvent("command", map[string]any{"entry": e}))
+			if sent++; limit > 0 && sent == limit {
+				cancel()
+			}
+		}
+		tick := time.NewTicker(heartbeat)
+		defer tick.Stop()
+		for {
+			select {
+			case e := <-entries:
+				emit(e)
+				tick.Reset(heartbeat)
+			case <-tick.C:
+				sse.send(newStreamEvent("waiting", nil))
+			case err := <-done:
+				for len(entries) > 0 {
+					emit(<-entries)
+				}
+				if err != nil && (limit == 0 || sent < limit) {
+					sse.send(newStreamEvent("error", map[string]any{"error": err.Error(), "commands": sent}))
+				} else {
+					sse.send(newStreamEvent("end", map[string]any{"commands": sent}))
+				}
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseMonitorLine pins the sdscatrepr reversal: the argument
+// vector must come back byte-exact, or the sniffed credentials are
+// garbage.
+func TestParseMonitorLine(t *testing.T) {
+	e := parseMonitorLine(`1339518083.107412 [0 10.0.0.7:60866] "AUTH" "chain" "p\"w\\d\x00\n"`)
+	want := monitorEntry{Time: "1339518083.107412", Client: "10.0.0.7:60866", Args: []string{"AUTH", "chain", "p\"w\\d\x00\n"}}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("parse = %+v, want %+v", e, want)
+	}
+	if e := parseMonitorLine("not a monitor line"); e.Raw != "not a monitor line" || e.Args != nil {
+		t.Errorf("unparseable line = %+v, want Raw only", e)
+	}
+}
+
+// TestAdminMonitor_StreamsFeed pins the endpoint over a real respRedis:
+// MONITOR goes out on its own connection, the +OK is swallowed, and
+// max=1 stops the feed after the first command.
+func TestAdminMonitor_StreamsFeed(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"+OK\r\n" +
+		"+1339518083.107412 [0 10.0.0.7:60866] \"HSET\" \"session:42\" \"token\" \"Bearer x\"\r\n" +
+		"+1339518083.200000 [0 10.0.0.8:50000] \"GET\" \"k\"\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	srv := newServer(nil, rd)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/monitor?max=1", nil))
+
+	evs := readSSE(t, rec.Body.String())
+	if got := eventNames(evs); got != "command end" {
+		t.Fatalf("events = %q; body=%s", got, rec.Body.String())
+	}
+	entry := evs[0].data["entry"].(map[string]any)
+	if entry["client"] != "10.0.0.7:60866" || len(entry["args"].([]any)) != 4 {
+		t.Errorf("entry = %v", entry)
+	}
+	if seen := <-cmds; len(seen) != 1 || strings.Join(seen[0], " ") != "MONITOR" {
+		t.Errorf("server saw %q, want MONITOR", seen)
+	}
+}
+
+func TestAdminMonitor_UnsupportedClient(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newServer(nil, &stubRedis{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/monitor", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", rec.Code)
+	}
+}
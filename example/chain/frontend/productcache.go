@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Keys and channel of the product read-through cache. They live under
+// chain:* so the keyspace feed on /api/events shows fills and expiries.
+const (
+	productCachePrefix  = "chain:cache:"
+	productInvalidateCh = "chain:cache:invalidate"
+
+	// productCacheTimeout bounds each cache lookup and each fill.
+	productCacheTimeout = 2 * time.Second
+)
+
+// cachedBackend is a backendClient that reads through a cacheStore
+// (redis GET/SETEX, or memcached get/set): a hit is answered from the
+// cache, a miss goes to the backend and a 200 is stored for ttl().
+// Only the bare catalog is cached: a path with a query string (search,
+// paging, sort) always goes to the backend, so keys stay bounded,
+// invalidation by path stays complete, and every ORDER BY payload
+// reaches postgres. Concurrent misses for the same path share one
+// backend request. With redis, a message on productInvalidateCh
+// (payload: the path, empty for /api/products) drops the entry, so a
+// catalog change shows up without waiting out the TTL:
+//
+//	PUBLISH chain:cache:invalidate /api/products
+//
+// Cache trouble never fails a read; the cache is skipped and the
+// backend answers.
+type cachedBackend struct {
+	be    backendClient
+	store cacheStore
+	ttl   func() time.Duration // read per request; 0 bypasses the cache
+
+	flights flightGroup
+	// gen counts invalidations; a fill that started before one is not
+	// stored, so a slow backend can't resurrect stale data.
+	gen atomic.Uint64
+}
+
+func newCachedBackend(be backendClient, store cacheStore, ttl func() time.Duration) *cachedBackend {
+	return &cachedBackend{be: be, store: store, ttl: ttl}
+}
+
+func (c *cachedBackend) Get(path string) (int, string, error) {
+	ttl := c.ttl()
+	if ttl <= 0 || strings.Contains(path, "?") {
+		return c.be.Get(path)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), productCacheTimeout)
+	defer cancel()
+	key := productCachePrefix + path
+	if body, ok, err := c.store.Get(ctx, key); err == nil && ok {
+		return 200, body, nil
+	}
+	r := c.flights.do(key, func() flightResult {
+		gen := c.gen.Load()
+		status, body, err := c.be.Get(path)
+		if err == nil && status == 200 && c.gen.Load() == gen {
+			// The fill is shared by every caller waiting on this
+			// flight, so it runs on its own deadline rather than the
+			// first caller's, whatever the backend call used up.
+			ctx, cancel := context.WithTimeout(context.Background(), productCacheTimeout)
+			defer cancel()
+			if err := c.store.Set(ctx, key, body, ttl); err != nil {
+				log.Printf("WARN: product cache fill %s: %v", key, err)
+			}
+		}
+		return flightResult{status, body, err}
+	})
+	return r.status, r.body, r.err
+}
+
+// Send passes writes straight to the wrapped backend; only Get is
+// cached.
+func (c *cachedBackend) Send(method, path, contentType string, body []byte) (int, string, error) {
+	s, ok := c.be.(backendSender)
+	if !ok {
+		return 0, "", errBackendReadOnly
+	}
+	return s.Send(method, path, contentType, body)
+}
+
+// invalidate drops the cached entry for path.
+func (c *cachedBackend) invalidate(ctx context.Context, path string) {
+	if path == "" {
+		path = "/api/products"
+	}
+	c.gen.Add(1)
+	if err := c.store.Delete(ctx, productCachePrefix+path); err != nil {
+		log.Printf("WARN: product cache invalidate %s: %v", path, err)
+	}
+}
+
+// watch subscribes to productInvalidateCh on rd until ctx is done,
+// resubscribing after a second whenever the connection drops.
+func (c *cachedBackend) watch(ctx context.Context, rd redisClient) {
+	for ctx.Err() == nil {
+		err := rd.Subscribe(ctx, []string{productInvalidateCh}, nil, func(m pubsubMessage) {
+			if m.Kind == "message" {
+				c.invalidate(ctx, m.Payload)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("WARN: product cache subscription: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// flightResult is what one backend call returned.
+type flightResult struct {
+	status int
+	body   string
+	err    error
+}
+
+// flightGroup coalesces concurrent calls by key: while one call for a
+// key runs, later callers wait for and share its result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	res  flightResult
+}
+
+func (g *flightGroup) do(key string, fn func() flightResult) flightResult {
+	g.mu.Lock()
+	if f, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.res
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	f.res = fn()
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(f.done)
+	return f.res
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// kvRedis is a map-backed redis for GET / SETEX / DEL, recording the
+// verbs it served.
+type kvRedis struct {
+	stubRedis
+	mu    sync.Mutex
+	data  map[string]string
+	verbs []string
+}
+
+func (k *kvRedis) Do(_ context.Context, args ...string) (respValue, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.verbs = append(k.verbs, args[0])
+	if k.data == nil {
+		k.data = map[string]string{}
+	}
+	switch args[0] {
+	case "GET":
+		if v, ok := k.data[args[1]]; ok {
+			return respValue{Kind: respBulk, Str: v}, nil
+		}
+		return respValue{Kind: respNil}, nil
+	case "SETEX":
+		k.data[args[1]] = args[3]
+		return respValue{Kind: respSimple, Str: "OK"}, nil
+	case "DEL":
+		delete(k.data, args[1])
+		return respValue{Kind: respInt, Int: 1}, nil
+	}
+	return respValue{Kind: respError, Str: "ERR unexpected " + args[0]}, nil
+}
+
+func ttlOf(d time.Duration) func() time.Duration {
+	return func() time.Duration { return d }
+}
+
+// slowBackend counts calls and blocks each until release is closed.
+type slowBackend struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *slowBackend) Get(string) (int, string, error) {
+	s.calls.Add(1)
+	<-s.release
+	return 200, `[{"id":1}]`, nil
+}
+
+// TestCachedBackend_ReadThrough pins the cache contract: the first
+// read fills chain:cache:/api/products with SETEX, the second is
+// answered by GET without touching the backend.
+func TestCachedBackend_ReadThrough(t *testing.T) {
+	rd := &kvRedis{}
+	be := &stubBackend{}
+	c := newCachedBackend(be, redisStore{rd}, ttlOf(30*time.Second))
+
+	for i := 0; i < 2; i++ {
+		status, body, err := c.Get("/api/products")
+		if err != nil || status != 200 || body != `[{"id":1,"name":"sticker"}]` {
+			t.Fatalf("read %d = %d %q %v", i, status, body, err)
+		}
+		if i == 0 {
+			be.lastPath = ""
+		}
+	}
+	if be.lastPath != "" {
+		t.Error("second read went to the backend")
+	}
+	if rd.data["chain:cache:/api/products"] == "" {
+		t.Errorf("cache not filled; redis saw %q", rd.verbs)
+	}
+}
+
+// TestCachedBackend_QueryBypassesCache pins that search/sort variants
+// are never cached: each must reach the backend (and postgres), or a
+// repeated ORDER BY payload would be answered from redis.
+func TestCachedBackend_QueryBypassesCache(t *testing.T) {
+	rd := &kvRedis{}
+	be := &stubBackend{}
+	c := newCachedBackend(be, redisStore{rd}, ttlOf(30*time.Second))
+
+	const path = "/api/products?sort=(SELECT+pg_sleep(1))"
+	for i := 0; i < 2; i++ {
+		be.lastPath = ""
+		if _, _, err := c.Get(path); err != nil {
+			t.Fatal(err)
+		}
+		if be.lastPath != path {
+			t.Fatalf("read %d did not reach the backend", i)
+		}
+	}
+	if len(rd.verbs) != 0 {
+		t.Errorf("redis saw %q for a query path", rd.verbs)
+	}
+}
+
+// TestCachedBackend_CoalescesMisses checks that concurrent misses for
+// one path share a single backend request.
+func TestCachedBackend_CoalescesMisses(t *testing.T) {
+	be := &slowBackend{release: make(chan struct{})}
+	c := newCachedBackend(be, redisStore{&kvRedis{}}, ttlOf(30*time.Second))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, _, _ := c.Get("/api/products"); status != 200 {
+				t.Errorf("status = %d", status)
+			}
+		}()
+	}
+	for be.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // let the rest pile up behind it
+	close(be.release)
+	wg.Wait()
+	if n := be.calls.Load(); n != 1 {
+		t.Errorf("backend called %d times, want 1", n)
+	}
+}
+
+func TestCachedBackend_InvalidateMessageDropsEntry(t *testing.T) {
+	rd := &kvRedis{data: map[string]string{"chain:cache:/api/products": "stale"}}
+	rd.messages = []pubsubMessage{{Kind: "message", Channel: productInvalidateCh, Payload: "/api/products"}}
+	c := newCachedBackend(&stubBackend{}, redisStore{rd}, ttlOf(30*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c.watch(ctx, rd)
+
+	if _, ok := rd.data["chain:cache:/api/products"]; ok {
+		t.Error("entry survived invalidation")
+	}
+	if rd.subscribed[0][0] != productInvalidateCh {
+		t.Errorf("subscribed to %q", rd.subscribed)
+	}
+}
+
+// TestCachedBackend_RedisDownFallsThrough: the cache is an optimisation,
+// so a broken redis still serves products.
+func TestCachedBackend_RedisDownFallsThrough(t *testing.T) {
+	rd := &stubRedis{err: context.DeadlineExceeded}
+	status, _, err := newCachedBackend(&stubBackend{}, redisStore{rd}, ttlOf(time.Second)).Get("/api/products")
+	if err != nil || status != 200 {
+		t.Errorf("Get = %d, %v; want the backend's 200", status, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimiter is the middleware form of the pattern /api/cache/eval
+// advertises: every request runs the registered "ratelimit" script
+// (EVALSHA, EVAL on NOSCRIPT) against chain:ratelimit:<client IP>, so
+// benign browsing produces the same script traffic a real shop's
+// limiter does. Over the limit the answer is 429 with Retry-After.
+//
+// /healthz is exempt — readiness must not depend on redis — and a
+// redis failure lets the request through: the limiter protects the
+// shop, it must not take it down. After a failure it stops asking
+// redis for rateLimitBackoff, so an outage doesn't cost every request
+// a dial and a timeout.
+type rateLimiter struct {
+	rd      redisClient
+	scripts *scriptRegistry
+	limit   int
+	window  time.Duration
+
+	// prefix and seq make each hit's sorted-set member unique across
+	// replicas that see the same millisecond.
+	prefix string
+	seq    atomic.Uint64
+
+	// skipUntil is the UnixNano before which limiting is off after a
+	// redis failure.
+	skipUntil atomic.Int64
+}
+
+// rateLimitBackoff is how long a redis failure turns the limiter off.
+const rateLimitBackoff = 5 * time.Second
+
+func newRateLimiter(rd redisClient, scripts *scriptRegistry, limit int, window time.Duration) *rateLimiter {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return &rateLimiter{rd: rd, scripts: scripts, limit: limit, window: window, prefix: hex.EncodeToString(b)}
+}
+
+// allow records one hit for client and reports whether it is within
+// the limit, how many remain and, when refused, how long to wait.
+func (l *rateLimiter) allow(ctx context.Context, client string) (bool, int64, time.Duration, error) {
+	script := l.scripts.Lookup("ratelimit")
+	if script == nil {
+		return true, 0, 0, nil
+	}
+	now := time.Now().UnixMilli()
+	member := l.prefix + "-" + strconv.FormatUint(l.seq.Add(1), 10)
+	v, err := l.scripts.Call(ctx, l.rd, script, []string{"chain:ratelimit:" + client}, []string{
+		strconv.FormatInt(now, 10),
+		strconv.FormatInt(l.window.Milliseconds(), 10),
+		strconv.Itoa(l.limit),
+		member,
+	})
+	if err != nil {
+		return true, 0, 0, err
+	}
+	if v.Kind != respArray || len(v.Elems) != 3 {
+		return true, 0, 0, nil
+	}
+	return v.Elems[0].Int == 1, v.Elems[1].Int, time.Duration(v.Elems[2].Int) * time.Millisecond, nil
+}
+
+func (l *rateLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if time.Now().UnixNano() < l.skipUntil.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		ok, remaining, retry, err := l.allow(r.Context(), client)
+		if err != nil {
+			l.skipUntil.Store(time.Now().Add(rateLimitBackoff).UnixNano())
+			log.Printf("WARN: rate limit %s: %v (allowing everyone for %v)", client, err, rateLimitBackoff)
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		if !ok {
+			secs := int64((retry + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.FormatInt(max(secs, 1), 10))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func limitReply(allowed, remaining, retryMS int64) respValue {
+	return respValue{Kind: respArray, Elems: []respValue{
+		{Kind: respInt, Int: allowed}, {Kind: respInt, Int: remaining}, {Kind: respInt, Int: retryMS},
+	}}
+}
+
+// TestRateLimit_RunsScriptPerRequest pins the traffic shape the
+// limiter exists for: every request is one EVALSHA of the registered
+// "ratelimit" script keyed by the client IP.
+func TestRateLimit_RunsScriptPerRequest(t *testing.T) {
+	rd := &stubRedis{reply: limitReply(1, 9, 0)}
+	srv := newServer(&stubBackend{}, rd, withRateLimit(10, time.Minute))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/products", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	sha := newScriptRegistry(defaultScripts).Lookup("ratelimit").SHA
+	if len(rd.lastCmd) != 8 || rd.lastCmd[0] != "EVALSHA" || rd.lastCmd[1] != sha || rd.lastCmd[3] != "chain:ratelimit:192.0.2.1" {
+		t.Errorf("cmd = %q", rd.lastCmd)
+	}
+	if rd.lastCmd[5] != "60000" || rd.lastCmd[6] != "10" {
+		t.Errorf("window/limit args = %q", rd.lastCmd[4:])
+	}
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "9" {
+		t.Errorf("X-RateLimit-Remaining = %q", got)
+	}
+}
+
+func TestRateLimit_RefusedIs429WithRetryAfter(t *testing.T) {
+	rd := &stubRedis{reply: limitReply(0, 0, 1500)}
+	srv := newServer(&stubBackend{}, rd, withRateLimit(10, time.Minute))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/products", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2 (1.5s rounded up)", got)
+	}
+}
+
+// TestRateLimit_FailsOpen: a redis outage must not turn into a shop
+// outage, and readiness never touches redis at all.
+func TestRateLimit_FailsOpen(t *testing.T) {
+	rd := &stubRedis{err: errors.New("connection refused")}
+	srv := newServer(&stubBackend{}, rd, withRateLimit(10, time.Minute))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/products", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 when redis is down", rec.Code)
+	}
+
+	rd.lastCmd = nil
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rd.lastCmd != nil {
+		t.Errorf("/healthz = %d, redis cmd %q; want 200 and no redis call", rec.Code, rd.lastCmd)
+	}
+}
+
+// TestRateLimit_BacksOffAfterRedisError pins that an outage costs one
+// failed call per backoff, not one per request.
+func TestRateLimit_BacksOffAfterRedisError(t *testing.T) {
+	rd := &stubRedis{err: errors.New("connection refused")}
+	l := newRateLimiter(rd, newScriptRegistry(defaultScripts), 10, time.Minute)
+	h := l.wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	get := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/products", nil))
+		return rec.Code
+	}
+
+	if code := get(); code != http.StatusOK || rd.lastCmd == nil {
+		t.Fatalf("first request: status %d, redis cmd %q", code, rd.lastCmd)
+	}
+	rd.lastCmd = nil
+	if code := get(); code != http.StatusOK || rd.lastCmd != nil {
+		t.Errorf("during backoff: status %d, redis cmd %q; want 200 and no redis call", code, rd.lastCmd)
+	}
+
+	l.skipUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	rd.err, rd.reply = nil, limitReply(0, 0, 1000)
+	if code := get(); code != http.StatusTooManyRequests {
+		t.Errorf("after backoff: status %d, want the limiter back (429)", code)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// respRedis is a zero-dep, pooled RESP client. Just enough to send
+// EVAL (and friends) and parse the reply. We intentionally don't
+// import pkg/attack/resp — keeps the frontend's go.mod isolated from
+// the rest of the bob codebase.
+//
+// Connections are kept in a LIFO idle pool and reused across requests
+// so a load test measures redis, not TCP handshakes. Every command
+// honours its context: an HTTP client hanging up aborts the in-flight
+// read and the (now desynchronised) connection is discarded instead of
+// being returned to the pool.
+type respRedis struct {
+	addr string
+	opts redisOptions
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+	sem    chan struct{} // nil when MaxActive is unlimited
+
+	dials, reuses, discards, cancels, waits atomic.Int64
+	active                                  atomic.Int64
+}
+
+// redisOptions is the connection-level config, populated from env in
+// main(). The zero value is usable: RESP2, 8 idle, unlimited active.
+type redisOptions struct {
+	// Proto selects the wire protocol: 2 sends commands straight away;
+	// 3 negotiates `HELLO 3` on every fresh connection first, so the
+	// redis pod sees the same handshake a RESP3-only client sends.
+	Proto int
+	// MaxIdle caps the idle pool; extra connections are closed on
+	// release.
+	MaxIdle int
+	// MaxActive caps checked-out connections; callers wait (bounded by
+	// their context) when it is reached. 0 means unlimited.
+	MaxActive int
+	// IdleTimeout closes pooled connections unused for this long.
+	IdleTimeout time.Duration
+	// DialTimeout bounds the TCP connect.
+	DialTimeout time.Duration
+	// Timeout is the per-command deadline applied when the caller's
+	// context carries none.
+	Timeout time.Duration
+	// Auth is sent on every fresh connection (AUTH, or folded into
+	// HELLO 3) when it resolves to a non-empty password.
+	Auth redisCredentials
+	// TLS, when non-nil, wraps every connection in TLS.
+	TLS *tls.Config
+	// Limits bounds reply sizes; see respLimits.
+	Limits respLimits
+}
+
+// poolStats is the snapshot served by /api/cache/pool.
+type poolStats struct {
+	Addr     string `json:"addr"`
+	Dials    int64  `json:"dials"`
+	Reuses   int64  `json:"reuses"`
+	Discards int64  `json:"discards"`
+	Cancels  int64  `json:"cancels"`
+	Waits    int64  `json:"waits"`
+	Active   int64  `json:"active"`
+	Idle     int    `json:"idle"`
+}
+
+// redisConn is one pooled connection with its buffered reader/writer.
+type redisConn struct {
+	net.Conn
+	br       *bufio.Reader
+	bw       *bufio.Writer
+	lastUsed time.Time
+}
+
+func newRespRedis(addr string, opts redisOptions) *respRedis {
+	if opts.Proto == 0 {
+		opts.Proto = 2
+	}
+	if opts.MaxIdle == 0 {
+		opts.MaxIdle = 8
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	r := &respRedis{addr: addr, opts: opts}
+	if opts.MaxActive > 0 {
+		r.sem = make(chan struct{}, opts.MaxActive)
+	}
+	return r
+}
+
+// Do sends one command and returns its reply. A top-level redis error
+// reply comes back as a *redisError; errors nested inside aggregates
+// (e.g. a failed element of an EXEC) stay in the reply tree.
+func (r *respRedis) Do(ctx context.Context, args ...string) (respValue, error) {
+	replies, err := r.Pipeline(ctx, args)
+	if err != nil {
+		return respValue{}, err
+	}
+	return replies[0], replies[0].Err()
+}
+
+// Pipeline writes every command in one flush and then reads the
+// replies in order over a single pooled connection. Error replies are
+// returned in place, one per command; the error result is reserved for
+// transport failures and cancellation.
+func (r *respRedis) Pipeline(ctx context.Context, cmds ...[]string) ([]respValue, error) {
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, stopped, err := r.roundTrip(ctx, c, cmds)
+	r.put(c, err == nil && stopped)
+	return replies, err
+}
+
+// Stats reports pool counters for /api/cache/pool.
+func (r *respRedis) Stats() poolStats {
+	r.mu.Lock()
+	idle := len(r.idle)
+	r.mu.Unlock()
+	return poolStats{
+		Addr:     r.addr,
+		Dials:    r.dials.Load(),
+		Reuses:   r.reuses.Load(),
+		Discards: r.discards.Load(),
+		Cancels:  r.cancels.Load(),
+		Waits:    r.waits.Load(),
+		Active:   r.active.Load(),
+		Idle:     idle,
+	}
+}
+
+// roundTrip runs cmds on c under the context's deadline (or
+// opts.Timeout). stopped is guard's: false means c must not be reused.
+func (r *respRedis) roundTrip(ctx context.Context, c *redisConn, cmds [][]string) ([]respValue, bool, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(r.opts.Timeout)
+	}
+	var replies []respValue
+	stopped, err := r.guard(ctx, c, deadline, func() (err error) {
+		replies, err = r.exchange(c, cmds)
+		return err
+	})
+	return replies, stopped, err
+}
+
+// guard runs fn with deadline as c's socket deadline (zero: none);
+// cancellation yanks the deadline into the past so a blocked read
+// returns immediately, and is reported as the context's error.
+//
+// stopped reports whether the cancellation hook was disarmed before it
+// ran. When it is false the hook may still be about to yank c's
+// deadline — possibly after the next borrower has set its own — so c
+// must be closed even though fn succeeded.
+func (r *respRedis) guard(ctx context.Context, c *redisConn, deadline time.Time, fn func() error) (stopped bool, err error) {
+	_ = c.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = c.SetDeadline(time.Unix(1, 0)) })
+
+	err = fn()
+	stopped = stop()
+	if err != nil && ctx.Err() != nil {
+		r.cancels.Add(1)
+		return stopped, fmt.Errorf("redis: %w", ctx.Err())
+	}
+	return stopped, err
+}
+
+// exchange writes cmds and reads len(cmds) replies.
+func (r *respRedis) exchange(c *redisConn, cmds [][]string) ([]respValue, error) {
+	for _, cmd := range cmds {
+		if _, err := c.bw.Write(encodeCommand(cmd...)); err != nil {
+			return nil, fmt.Errorf("write: %w", err)
+		}
+	}
+	if err := c.bw.Flush(); err != nil {
+		return nil, fmt.Errorf("write: %w", err)
+	}
+	replies := make([]respValue, len(cmds))
+	for i := range cmds {
+		v, err := readRespReply(c.br, r.opts.Limits)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = v
+	}
+	return replies, nil
+}
+
+// connStateCommands change what a connection does after them: open a
+// transaction or subscription, switch database, protocol or user,
+// stream a feed, silence replies or close it. Sent through a pooled
+// connection they leave it broken for the next borrower, so endpoints
+// forwarding caller-supplied commands refuse them; /api/admin/monitor
+// and /api/events cover the feeds on connections of their own.
+var connStateCommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"MONITOR": true, "SYNC": true, "PSYNC": true, "REPLCONF": true,
+	"SELECT": true, "CLIENT": true, "HELLO": true, "RESET": true, "AUTH": true,
+	"READONLY": true, "READWRITE": true, "QUIT": true,
+}
+
+// changesConnState reports whether cmd is one of connStateCommands.
+func changesConnState(cmd []string) bool {
+	return len(cmd) > 0 && connStateCommands[strings.ToUpper(cmd[0])]
+}
+
+// dedicated sends cmds on a fresh connection that never returns to
+// the pool — it is left in a mode (SUBSCRIBE, MONITOR) where it can't
+// run other commands — and hands every frame that follows to fn. It
+// returns nil once ctx is done, else the error that ended the feed.
+// There is no read deadline: long idle stretches are the normal case,
+// and TCP keepalive notices a dead peer.
+func (r *respRedis) dedicated(ctx context.Context, cmds [][]string, fn func(respValue) error) error {
+	c, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+	r.dials.Add(1)
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { _ = c.Close() })
+	defer stop()
+
+	_ = c.SetDeadline(time.Time{})
+	for _, cmd := range cmds {
+		if _, err := c.bw.Write(encodeCommand(cmd...)); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+	if err = c.bw.Flush(); err == nil {
+		err = readRespEach(c.br, r.opts.Limits, fn)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// get checks out a connection: the freshest idle one that hasn't aged
+// out, else a new dial.
+func (r *respRedis) get(ctx context.Context) (*redisConn, error) {
+	if r.sem != nil {
+		select {
+		case r.sem <- struct{}{}:
+		default:
+			r.waits.Add(1)
+			select {
+			case r.sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, fmt.Errorf("redis pool: %w", ctx.Err())
+			}
+		}
+	}
+	r.active.Add(1)
+
+	r.mu.Lock()
+	for len(r.idle) > 0 {
+		c := r.idle[len(r.idle)-1]
+		r.idle = r.idle[:len(r.idle)-1]
+		if r.opts.IdleTimeout > 0 && time.Since(c.lastUsed) > r.opts.IdleTimeout {
+			_ = c.Close()
+			r.discards.Add(1)
+			continue
+		}
+		r.mu.Unlock()
+		r.reuses.Add(1)
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	c, err := r.dial(ctx)
+	if err != nil {
+		r.release()
+		return nil, err
+	}
+	r.dials.Add(1)
+	return c, nil
+}
+
+// put returns c to the idle pool, or closes it when it is unhealthy
+// (mid-reply when an error hit) or the pool is full.
+func (r *respRedis) put(c *redisConn, healthy bool) {
+	defer r.release()
+	if healthy {
+		c.lastUsed = time.Now()
+		r.mu.Lock()
+		if !r.closed && len(r.idle) < r.opts.MaxIdle {
+			r.idle = append(r.idle, c)
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+	}
+	_ = c.Close()
+	r.discards.Add(1)
+}
+
+func (r *respRedis) release() {
+	r.active.Add(-1)
+	if r.sem != nil {
+		<-r.sem
+	}
+}
+
+// dial opens a connection and runs the per-connection handshake:
+// TLS (when configured), then HELLO 3 and/or AUTH.
+func (r *respRedis) dial(ctx context.Context) (*redisConn, error) {
+	nd := &net.Dialer{Timeout: r.opts.DialTimeout}
+	var (
+		nc  net.Conn
+		err error
+	)
+	if r.opts.TLS != nil {
+		nc, err = (&tls.Dialer{NetDialer: nd, Config: r.opts.TLS}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		nc, err = nd.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	c := &redisConn{Conn: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}
+	hs, err := r.handshake()
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	if hs != nil {
+		replies, stopped, err := r.roundTrip(ctx, c, [][]string{hs})
+		if err == nil && !stopped {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = replies[0].Err()
+		}
+		if err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("%s: %w", strings.ToLower(hs[0]), err)
+		}
+	}
+	return c, nil
+}
+
+// handshake returns the command a fresh connection must send first:
+// HELLO 3 [AUTH user pass] in RESP3 mode, AUTH [user] pass in RESP2
+// mode with credentials, nil otherwise. Credentials are resolved on
+// every dial so a rotated secret mount is picked up without a restart.
+func (r *respRedis) handshake() ([]string, error) {
+	user, pass, err := r.opts.Auth.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	switch {
+	case r.opts.Proto == 3 && pass != "":
+		if user == "" {
+			user = "default" // HELLO AUTH always names a user
+		}
+		return []string{"HELLO", "3", "AUTH", user, pass}, nil
+	case r.opts.Proto == 3:
+		return []string{"HELLO", "3"}, nil
+	case pass != "" && user != "":
+		return []string{"AUTH", user, pass}, nil
+	case pass != "":
+		return []string{"AUTH", pass}, nil
+	}
+	return nil, nil
+}
+
+// Close drops every idle connection. In-flight ones are closed as
+// they are returned.
+func (r *respRedis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.idle {
+		_ = c.Close()
+	}
+	r.idle = nil
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRespRedis_Proto3SendsHello pins the RESP3 handshake: the first
+// command on a fresh connection MUST be HELLO 3, then the real command.
+func TestRespRedis_Proto3SendsHello(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{
+		"%1\r\n+proto\r\n:3\r\n",
+		"#t\r\n",
+	})
+	rd := newRespRedis(addr, redisOptions{Proto: 3})
+	got, err := rd.Do(context.Background(), "EXISTS", "k")
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if got.Kind != respBool || got.Int != 1 {
+		t.Errorf("reply = %+v, want bool true", got)
+	}
+	seen := <-cmds
+	if len(seen) != 2 || strings.Join(seen[0], " ") != "HELLO 3" || seen[1][0] != "EXISTS" {
+		t.Errorf("server saw %v, want [HELLO 3] then [EXISTS k]", seen)
+	}
+}
+
+// TestRespRedis_ReusesPooledConnection pins the point of the pool:
+// back-to-back commands share one TCP connection (fakeRedis only ever
+// accepts one), so the frontend's network profile sees no churn.
+func TestRespRedis_ReusesPooledConnection(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{":1\r\n", ":2\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	for want := int64(1); want <= 2; want++ {
+		v, err := rd.Do(context.Background(), "INCR", "chain:counter")
+		if err != nil {
+			t.Fatalf("Do #%d: %v", want, err)
+		}
+		if v.Int != want {
+			t.Errorf("Do #%d = %d, want %d", want, v.Int, want)
+		}
+	}
+	st := rd.Stats()
+	if st.Dials != 1 || st.Reuses != 1 || st.Idle != 1 {
+		t.Errorf("stats = %+v, want 1 dial, 1 reuse, 1 idle", st)
+	}
+	rd.Close()
+	<-cmds
+}
+
+// TestRespRedis_Pipeline pins that pipelined commands come back
+// in order with error replies left in place rather than aborting.
+func TestRespRedis_Pipeline(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"+OK\r\n", "-WRONGTYPE nope\r\n", "$3\r\nbar\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	got, err := rd.Pipeline(context.Background(),
+		[]string{"SET", "foo", "bar"}, []string{"LPUSH", "foo", "x"}, []string{"GET", "foo"})
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if len(got) != 3 || got[0].Str != "OK" || got[1].Kind != respError || got[2].Str != "bar" {
+		t.Errorf("Pipeline = %+v, want [OK, error, bar]", got)
+	}
+	rd.Close()
+	if seen := <-cmds; len(seen) != 3 {
+		t.Errorf("server saw %d commands, want 3", len(seen))
+	}
+}
+
+// TestRespRedis_CancelAbortsInFlight pins cancel-on-disconnect: a
+// command stuck waiting on redis returns as soon as the caller's
+// context is cancelled, and the connection is not put back.
+func TestRespRedis_CancelAbortsInFlight(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn) // swallow EVAL, never reply
+	}()
+
+	rd := newRespRedis(ln.Addr().String(), redisOptions{Timeout: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = rd.Do(ctx, "EVAL", "while true do end", "0")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Do returned after %v, want prompt cancel", d)
+	}
+	if st := rd.Stats(); st.Cancels != 1 || st.Idle != 0 || st.Active != 0 {
+		t.Errorf("stats = %+v, want 1 cancel, nothing idle or active", st)
+	}
+}
+
+// TestRespRedis_LateCancelDiscardsConnection pins the race guard
+// closes: a context cancelled just after the reply arrived means the
+// deadline hook may still fire, so the connection must not be reused.
+func TestRespRedis_LateCancelDiscardsConnection(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"+OK\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	c, err := rd.get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped, err := rd.guard(ctx, c, time.Time{}, func() error {
+		_, err := rd.exchange(c, [][]string{{"PING"}})
+		cancel() // the reply is in; the hook now races the caller
+		return err
+	})
+	if err != nil || stopped {
+		t.Fatalf("guard = %v, %v; want success with the hook not stopped", stopped, err)
+	}
+	rd.put(c, err == nil && stopped)
+	if st := rd.Stats(); st.Idle != 0 || st.Discards != 1 {
+		t.Errorf("stats = %+v, want the connection discarded", st)
+	}
+	<-cmds
+}
+
+// fakeRedis accepts one connection, answers each command it reads with
+// the next canned reply, and reports every command vector it saw once
+// the replies run out.
+func fakeRedis(t *testing.T, replies []string) (string, <-chan [][]string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return serveFakeRedis(t, ln, replies)
+}
+
+// serveFakeRedis is fakeRedis over a caller-supplied listener (e.g. a
+// TLS one).
+func serveFakeRedis(t *testing.T, ln net.Listener, replies []string) (string, <-chan [][]string) {
+	t.Helper()
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan [][]string, 1)
+	go func() {
+		var seen [][]string
+		defer func() { out <- seen }()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for _, reply := range replies {
+			cmd, err := readFakeCommand(br)
+			if err != nil {
+				return
+			}
+			seen = append(seen, cmd)
+			if _, err := conn.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+// readFakeCommand decodes one client command (array of bulk strings).
+func readFakeCommand(br *bufio.Reader) ([]string, error) {
+	v, err := readRespReply(br, respLimits{})
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, len(v.Elems))
+	for i, e := range v.Elems {
+		cmd[i] = e.Str
+	}
+	return cmd, nil
+}
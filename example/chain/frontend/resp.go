@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// encodeCommand renders args as a RESP array of bulk strings — the
+// only request shape redis accepts from non-inline clients.
+func encodeCommand(args ...string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return []byte(b.String())
+}
+
+// ── reply tree ───────────────────────────────────────────────────
+
+// respKind tags a respValue with the wire type it was decoded from.
+type respKind uint8
+
+const (
+	respNil      respKind = iota // $-1, *-1, RESP3 _
+	respSimple                   // +
+	respError                    // -, RESP3 !
+	respInt                      // :
+	respBulk                     // $
+	respArray                    // *
+	respDouble                   // RESP3 ,
+	respBigNum                   // RESP3 (
+	respBool                     // RESP3 #
+	respVerbatim                 // RESP3 =
+	respMap                      // RESP3 %
+	respSet                      // RESP3 ~
+	respPush                     // RESP3 >
+)
+
+var respKindNames = [...]string{
+	respNil: "nil", respSimple: "simple", respError: "error", respInt: "int",
+	respBulk: "bulk", respArray: "array", respDouble: "double", respBigNum: "bignum",
+	respBool: "bool", respVerbatim: "verbatim", respMap: "map", respSet: "set",
+	respPush: "push",
+}
+
+func (k respKind) String() string {
+	if int(k) < len(respKindNames) {
+		return respKindNames[k]
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// respValue is one decoded reply. Str carries the payload of every
+// scalar except int/bool (doubles and big numbers keep their wire
+// text so nothing is lost to float rounding); Int carries integers
+// and booleans (0/1); Elems carries aggregate children — for maps
+// they alternate key, value, key, value.
+type respValue struct {
+	Kind  respKind
+	Str   string
+	Int   int64
+	Elems []respValue
+}
+
+// redisError is a redis error reply surfaced as a Go error.
+type redisError struct{ msg string }
+
+func (e *redisError) Error() string { return "redis: " + e.msg }
+
+// Err returns the reply as a *redisError when it is an error reply.
+func (v respValue) Err() error {
+	if v.Kind == respError {
+		return &redisError{msg: v.Str}
+	}
+	return nil
+}
+
+// String renders scalars as their text and aggregates as a
+// newline-joined list — the shape the frontend returned before
+// replies were typed, still handy for logs.
+func (v respValue) String() string {
+	switch v.Kind {
+	case respNil:
+		return ""
+	case respInt:
+		return strconv.FormatInt(v.Int, 10)
+	case respBool:
+		return strconv.FormatBool(v.Int != 0)
+	case respArray, respMap, respSet, respPush:
+		parts := make([]string, len(v.Elems))
+		for i, e := range v.Elems {
+			parts[i] = e.String()
+		}
+		return strings.Join(parts, "\n")
+	}
+	return v.Str
+}
+
+// MarshalJSON encodes the reply structurally: nil → null, integers
+// and finite doubles → numbers, strings → JSON strings, errors →
+// {"error":…}, arrays/sets/pushes → arrays, maps → objects keyed by
+// the key's String().
+func (v respValue) MarshalJSON() ([]byte, error) {
+	switch v.Kind {
+	case respNil:
+		return []byte("null"), nil
+	case respInt:
+		return strconv.AppendInt(nil, v.Int, 10), nil
+	case respBool:
+		return strconv.AppendBool(nil, v.Int != 0), nil
+	case respBigNum:
+		return []byte(v.Str), nil
+	case respDouble:
+		f, err := strconv.ParseFloat(v.Str, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return json.Marshal(v.Str)
+		}
+		return strconv.AppendFloat(nil, f, 'g', -1, 64), nil
+	case respError:
+		return json.Marshal(struct {
+			Error string `json:"error"`
+		}{v.Str})
+	case respArray, respSet, respPush:
+		if v.Elems == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(v.Elems)
+	case respMap:
+		m := make(map[string]respValue, len(v.Elems)/2)
+		for i := 0; i+1 < len(v.Elems); i += 2 {
+			m[v.Elems[i].String()] = v.Elems[i+1]
+		}
+		return json.Marshal(m)
+	}
+	return json.Marshal(v.Str)
+}
+
+// Base64 returns a copy of v with every string payload — simple,
+// bulk and verbatim, at any depth, map keys included — replaced by its
+// standard base64 encoding, so a reply carrying raw bytes survives the
+// JSON envelope exactly. Errors, numbers and nil are left as they are.
+func (v respValue) Base64() respValue {
+	switch v.Kind {
+	case respSimple, respBulk, respVerbatim:
+		v.Str = base64.StdEncoding.EncodeToString([]byte(v.Str))
+	case respArray, respMap, respSet, respPush:
+		if v.Elems != nil {
+			elems := make([]respValue, len(v.Elems))
+			for i, e := range v.Elems {
+				elems[i] = e.Base64()
+			}
+			v.Elems = elems
+		}
+	}
+	return v
+}
+
+// ── parser ───────────────────────────────────────────────────────
+
+// respLimits bounds what the parser will accept from the wire. redis
+// is not trusted here: a rogue or broken server (see the rogue-master
+// scenarios in example/redis/redis-tests) can announce a 2 GiB bulk or
+// an endlessly nested array, and without limits the frontend pod
+// OOMs. Zero fields fall back to defaultRespLimits.
+type respLimits struct {
+	// MaxBulk caps the payload of one bulk / verbatim / blob-error
+	// frame, and the length of one simple-string / error line.
+	MaxBulk int64
+	// MaxArray caps the element count of one aggregate (map entries
+	// count twice).
+	MaxArray int64
+	// MaxDepth caps aggregate nesting; a flat array is depth 1.
+	MaxDepth int
+	// MaxReplyBytes caps the wire bytes of one whole reply, headers
+	// and payloads of every frame at every depth together.
+	MaxReplyBytes int64
+	// MaxReplyElems caps the frames one whole reply decodes to. Each
+	// costs a respValue (~56 bytes) however small it is on the wire,
+	// so a nest of ":0" frames is bounded here, not by MaxReplyBytes.
+	MaxReplyElems int64
+}
+
+// defaultRespLimits carries every reply the chain stages produce. The
+// per-frame caps alone don't bound a reply — a million 32 MiB bulks
+// passes all three — so the reply budget does: one reply decodes to
+// at most 32 MiB of payload plus 1M values (~56 MiB), which leaves
+// room in the frontend's 256Mi limit to encode it. Concurrent replies
+// each get their own budget; REDIS_POOL_MAX_ACTIVE bounds how many.
+var defaultRespLimits = respLimits{
+	MaxBulk: 32 << 20, MaxArray: 1 << 20, MaxDepth: 32,
+	MaxReplyBytes: 32 << 20, MaxReplyElems: 1 << 20,
+}
+
+func (l respLimits) withDefaults() respLimits {
+	if l.MaxBulk <= 0 {
+		l.MaxBulk = defaultRespLimits.MaxBulk
+	}
+	if l.MaxArray <= 0 {
+		l.MaxArray = defaultRespLimits.MaxArray
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = defaultRespLimits.MaxDepth
+	}
+	if l.MaxReplyBytes <= 0 {
+		l.MaxReplyBytes = defaultRespLimits.MaxReplyBytes
+	}
+	if l.MaxReplyElems <= 0 {
+		l.MaxReplyElems = defaultRespLimits.MaxReplyElems
+	}
+	return l
+}
+
+// respBudget is the limits plus what the reply being parsed has spent
+// of MaxReplyBytes and MaxReplyElems. Every top-level frame starts a
+// fresh one.
+type respBudget struct {
+	respLimits
+	bytes, elems int64
+}
+
+func newRespBudget(lim respLimits) *respBudget {
+	return &respBudget{respLimits: lim.withDefaults()}
+}
+
+// spend charges n wire bytes and elems frames to the reply.
+func (b *respBudget) spend(n, elems int64) error {
+	b.bytes += n
+	b.elems += elems
+	if b.bytes > b.MaxReplyBytes || b.elems > b.MaxReplyElems {
+		return &replyTooLargeError{Bytes: b.bytes, Elems: b.elems, MaxBytes: b.MaxReplyBytes, MaxElems: b.MaxReplyElems}
+	}
+	return nil
+}
+
+// bulkTooLargeError reports a bulk (or line) over respLimits.MaxBulk.
+type bulkTooLargeError struct{ Len, Max int64 }
+
+func (e *bulkTooLargeError) Error() string {
+	return fmt.Sprintf("resp: bulk of %d bytes exceeds limit %d", e.Len, e.Max)
+}
+
+// arrayTooLongError reports an aggregate over respLimits.MaxArray.
+type arrayTooLongError struct{ Len, Max int64 }
+
+func (e *arrayTooLongError) Error() string {
+	return fmt.Sprintf("resp: aggregate of %d elements exceeds limit %d", e.Len, e.Max)
+}
+
+// nestingTooDeepError reports aggregates nested past respLimits.MaxDepth.
+type nestingTooDeepError struct{ Max int }
+
+func (e *nestingTooDeepError) Error() string {
+	return fmt.Sprintf("resp: aggregates nested deeper than %d", e.Max)
+}
+
+// replyTooLargeError reports a reply whose frames each fit respLimits
+// but which together exceed MaxReplyBytes or MaxReplyElems.
+type replyTooLargeError struct{ Bytes, Elems, MaxBytes, MaxElems int64 }
+
+func (e *replyTooLargeError) Error() string {
+	return fmt.Sprintf("resp: reply of %d bytes / %d elements exceeds budget %d / %d",
+		e.Bytes, e.Elems, e.MaxBytes, e.MaxElems)
+}
+
+// readRespReply parses one RESP2 or RESP3 reply into a respValue.
+// Error replies are returned as values (Kind == respError) — the
+// returned error is only for transport / framing failures and limit
+// violations. Push frames (out-of-band pub/sub or client-tracking
+// messages) and attribute frames (metadata preceding a reply) are
+// consumed and skipped, so the caller always gets the reply to the
+// command it sent.
+func readRespReply(rd *bufio.Reader, lim respLimits) (respValue, error) {
+	for {
+		v, err := readRespValue(rd, newRespBudget(lim), 0)
+		if err != nil {
+			return respValue{}, err
+		}
+		if v.Kind == respPush {
+			continue
+		}
+		return v, nil
+	}
+}
+
+// readRespEach is the streaming variant of readRespReply for
+// connections that keep talking after one reply — MONITOR feeds and
+// subscribed connections. It hands every frame to fn, push frames
+// included, until fn or the read fails, and returns that error.
+func readRespEach(rd *bufio.Reader, lim respLimits, fn func(respValue) error) error {
+	for {
+		v, err := readRespValue(rd, newRespBudget(lim), 0)
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+// readRespStream is readRespReply for callers that report progress:
+// the elements of a top-level array or set reply are handed to each
+// as they come off the wire, before the whole reply is in. The
+// returned value is the same tree readRespReply would build.
+func readRespStream(rd *bufio.Reader, lim respLimits, each func(int, respValue)) (respValue, error) {
+	for {
+		b := newRespBudget(lim)
+		line, err := readRespLine(rd, b)
+		if err != nil {
+			return respValue{}, err
+		}
+		switch line[0] {
+		case '|': // attribute: annotates the reply that follows
+			if _, err := readRespAggregate(rd, b, 0, respMap, line, nil); err != nil {
+				return respValue{}, err
+			}
+			continue
+		case '>': // out-of-band push: not our reply
+			if _, err := readRespAggregate(rd, b, 0, respPush, line, nil); err != nil {
+				return respValue{}, err
+			}
+			continue
+		case '*':
+			return readRespAggregate(rd, b, 0, respArray, line, each)
+		case '~':
+			return readRespAggregate(rd, b, 0, respSet, line, each)
+		}
+		return readRespBody(rd, b, 0, line)
+	}
+}
+
+// readRespValue parses exactly one frame at nesting depth, push
+// frames included. Attribute frames are folded away since they only
+// annotate the frame that follows them.
+func readRespValue(rd *bufio.Reader, lim *respBudget, depth int) (respValue, error) {
+	for {
+		line, err := readRespLine(rd, lim)
+		if err != nil {
+			return respValue{}, err
+		}
+		if line[0] == '|' {
+			if _, err := readRespAggregate(rd, lim, depth, respMap, line, nil); err != nil {
+				return respValue{}, err
+			}
+			continue
+		}
+		return readRespBody(rd, lim, depth, line)
+	}
+}
+
+// readRespLine reads one CRLF-terminated header line and strips the
+// terminator, refusing lines longer than lim.MaxBulk.
+func readRespLine(rd *bufio.Reader, lim *respBudget) (string, error) {
+	var line []byte
+	for {
+		frag, err := rd.ReadSlice('\n')
+		line = append(line, frag...)
+		if int64(len(line)) > lim.MaxBulk+2 {
+			return "", &bulkTooLargeError{Len: int64(len(line)), Max: lim.MaxBulk}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("read header: %w", err)
+		}
+		break
+	}
+	s := strings.TrimRight(string(line), "\r\n")
+	if len(s) < 1 {
+		return "", fmt.Errorf("empty reply")
+	}
+	if err := lim.spend(int64(len(line)), 1); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// readRespBody decodes the frame whose header line has already been
+// read.
+func readRespBody(rd *bufio.Reader, lim *respBudget, depth int, line string) (respValue, error) {
+	switch line[0] {
+	case '+':
+		return respValue{Kind: respSimple, Str: line[1:]}, nil
+	case '-':
+		return respValue{Kind: respError, Str: line[1:]}, nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return respValue{}, fmt.Errorf("integer: %w", err)
+		}
+		return respValue{Kind: respInt, Int: n}, nil
+	case ',': // inf / -inf / nan kept as sent
+		return respValue{Kind: respDouble, Str: line[1:]}, nil
+	case '(':
+		if !isBigNum(line[1:]) {
+			return respValue{}, fmt.Errorf("bad big number %q", line)
+		}
+		return respValue{Kind: respBigNum, Str: line[1:]}, nil
+	case '#':
+		switch line[1:] {
+		case "t":
+			return respValue{Kind: respBool, Int: 1}, nil
+		case "f":
+			return respValue{Kind: respBool}, nil
+		}
+		return respValue{}, fmt.Errorf("bad boolean %q", line)
+	case '_':
+		return respValue{Kind: respNil}, nil
+	case '$':
+		return readRespBulk(rd, lim, respBulk, line)
+	case '!':
+		return readRespBulk(rd, lim, respError, line)
+	case '=': // "txt:" / "mkd:" format prefix is dropped
+		v, err := readRespBulk(rd, lim, respVerbatim, line)
+		if err != nil {
+			return respValue{}, err
+		}
+		if len(v.Str) < 4 || v.Str[3] != ':' {
+			return respValue{}, fmt.Errorf("verbatim string without format prefix")
+		}
+		v.Str = v.Str[4:]
+		return v, nil
+	case '*':
+		return readRespAggregate(rd, lim, depth, respArray, line, nil)
+	case '~':
+		return readRespAggregate(rd, lim, depth, respSet, line, nil)
+	case '%':
+		return readRespAggregate(rd, lim, depth, respMap, line, nil)
+	case '>':
+		return readRespAggregate(rd, lim, depth, respPush, line, nil)
+	}
+	return respValue{}, fmt.Errorf("unknown reply type %q", line)
+}
+
+// readRespBulk reads the payload of a length-prefixed frame ($, !, =).
+// A negative length is the RESP2 null bulk. The buffer grows with the
+// bytes that actually arrive rather than with the announced length,
+// so a lying prefix costs nothing until the server backs it with data.
+func readRespBulk(rd *bufio.Reader, lim *respBudget, kind respKind, line string) (respValue, error) {
+	n, err := strconv.ParseInt(line[1:], 10, 64)
+	if err != nil {
+		return respValue{}, fmt.Errorf("bulk len: %w", err)
+	}
+	if n < 0 {
+		return respValue{Kind: respNil}, nil
+	}
+	if n > lim.MaxBulk {
+		return respValue{}, &bulkTooLargeError{Len: n, Max: lim.MaxBulk}
+	}
+	if err := lim.spend(n+2, 0); err != nil {
+		return respValue{}, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, rd, n+2); err != nil { // payload + \r\n
+		return respValue{}, fmt.Errorf("bulk body: %w", err)
+	}
+	b := buf.Bytes()
+	if b[n] != '\r' || b[n+1] != '\n' {
+		return respValue{}, fmt.Errorf("bulk body: missing CRLF terminator")
+	}
+	return respValue{Kind: kind, Str: string(b[:n])}, nil
+}
+
+// readRespAggregate reads the children of an array, set, push or map
+// (maps and attributes carry two frames per entry). *-1 is the RESP2
+// null array; any other negative count is a framing error. each, when
+// non-nil, sees every child as soon as it is parsed.
+func readRespAggregate(rd *bufio.Reader, lim *respBudget, depth int, kind respKind, line string, each func(int, respValue)) (respValue, error) {
+	n, err := strconv.ParseInt(line[1:], 10, 64)
+	if err != nil {
+		return respValue{}, fmt.Errorf("aggregate len: %w", err)
+	}
+	if n == -1 && kind == respArray {
+		return respValue{Kind: respNil}, nil
+	}
+	if n < 0 {
+		return respValue{}, fmt.Errorf("aggregate len: negative count %d", n)
+	}
+	if kind == respMap {
+		// Check before doubling: a hostile count would overflow it.
+		if n > lim.MaxArray/2 {
+			return respValue{}, &arrayTooLongError{Len: min(n, math.MaxInt64/2) * 2, Max: lim.MaxArray}
+		}
+		n *= 2
+	}
+	if n > lim.MaxArray {
+		return respValue{}, &arrayTooLongError{Len: n, Max: lim.MaxArray}
+	}
+	if depth+1 > lim.MaxDepth {
+		return respValue{}, &nestingTooDeepError{Max: lim.MaxDepth}
+	}
+	v := respValue{Kind: kind, Elems: make([]respValue, 0, min(n, 1024))}
+	for i := int64(0); i < n; i++ {
+		e, err := readRespValue(rd, lim, depth+1)
+		if err != nil {
+			return respValue{}, err
+		}
+		if each != nil {
+			each(int(i), e)
+		}
+		v.Elems = append(v.Elems, e)
+	}
+	return v, nil
+}
+
+// isBigNum reports whether s is an optionally signed run of decimal
+// digits — the only shape RESP3 allows, and one JSON can carry as a
+// bare number.
+func isBigNum(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
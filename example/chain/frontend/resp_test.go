@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestReadRespReply_AllTypes pins the parser against one frame of every
+// RESP2 and RESP3 reply type the chain-redis image (7.2) can emit.
+func TestReadRespReply_AllTypes(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"simple", "+OK\r\n", "OK"},
+		{"integer", ":42\r\n", "42"},
+		{"bulk", "$5\r\nhello\r\n", "hello"},
+		{"nil bulk", "$-1\r\n", ""},
+		{"array", "*2\r\n$1\r\na\r\n:1\r\n", "a\n1"},
+		{"null", "_\r\n", ""},
+		{"double", ",3.14\r\n", "3.14"},
+		{"double inf", ",inf\r\n", "inf"},
+		{"bignum", "(3492890328409238509324850943850943825024385\r\n", "3492890328409238509324850943850943825024385"},
+		{"bool true", "#t\r\n", "true"},
+		{"bool false", "#f\r\n", "false"},
+		{"verbatim", "=15\r\ntxt:Some string\r\n", "Some string"},
+		{"map", "%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n", "first\n1\nsecond\n2"},
+		{"set", "~2\r\n+a\r\n+b\r\n", "a\nb"},
+		{"attribute skipped", "|1\r\n+ttl\r\n:3600\r\n:7\r\n", "7"},
+		{"push skipped", ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n+OK\r\n", "OK"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := readRespReply(bufio.NewReader(strings.NewReader(tc.in)), respLimits{})
+			if err != nil {
+				t.Fatalf("readRespReply(%q): %v", tc.in, err)
+			}
+			if got := v.String(); got != tc.want {
+				t.Errorf("readRespReply(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRespValue_MarshalJSON pins the typed envelope: nil is null,
+// not "", nested arrays stay nested, and maps become objects.
+func TestRespValue_MarshalJSON(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"$-1\r\n", `null`},
+		{"$0\r\n\r\n", `""`},
+		{":-7\r\n", `-7`},
+		{",1.5\r\n", `1.5`},
+		{",nan\r\n", `"nan"`},
+		{"#f\r\n", `false`},
+		{"*0\r\n", `[]`},
+		{"*-1\r\n", `null`},
+		{"*2\r\n*1\r\n:1\r\n-ERR x\r\n", `[[1],{"error":"ERR x"}]`},
+		{"%1\r\n+proto\r\n:3\r\n", `{"proto":3}`},
+	}
+	for _, tc := range cases {
+		v, err := readRespReply(bufio.NewReader(strings.NewReader(tc.in)), respLimits{})
+		if err != nil {
+			t.Fatalf("readRespReply(%q): %v", tc.in, err)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%q): %v", tc.in, err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("Marshal(%q) = %s, want %s", tc.in, b, tc.want)
+		}
+	}
+}
+
+func TestReadRespReply_Errors(t *testing.T) {
+	for _, in := range []string{
+		"-ERR wrong number of arguments\r\n",
+		"!21\r\nSYNTAX invalid syntax\r\n",
+	} {
+		v, err := readRespReply(bufio.NewReader(strings.NewReader(in)), respLimits{})
+		if err != nil {
+			t.Fatalf("readRespReply(%q): %v", in, err)
+		}
+		if v.Kind != respError || v.Err() == nil {
+			t.Errorf("readRespReply(%q) = %+v, want error reply", in, v)
+		}
+	}
+}
+
+// TestReadRespReply_Limits pins one distinct typed error per limit, and
+// that an oversized bulk is refused from its header alone — before
+// the parser allocates or waits for the announced payload.
+func TestReadRespReply_Limits(t *testing.T) {
+	lim := respLimits{MaxBulk: 16, MaxArray: 4, MaxDepth: 2}
+	read := func(in string) error {
+		_, err := readRespReply(bufio.NewReader(strings.NewReader(in)), lim)
+		return err
+	}
+
+	var bulkErr *bulkTooLargeError
+	if err := read("$2147483647\r\n"); !errors.As(err, &bulkErr) || bulkErr.Len != 2147483647 {
+		t.Errorf("2 GiB bulk header: err = %v, want *bulkTooLargeError", err)
+	}
+	if err := read("+" + strings.Repeat("x", 64) + "\r\n"); !errors.As(err, &bulkErr) {
+		t.Errorf("long simple string: err = %v, want *bulkTooLargeError", err)
+	}
+	var arrErr *arrayTooLongError
+	if err := read("*5\r\n"); !errors.As(err, &arrErr) {
+		t.Errorf("5-element array: err = %v, want *arrayTooLongError", err)
+	}
+	if err := read("%3\r\n"); !errors.As(err, &arrErr) || arrErr.Len != 6 {
+		t.Errorf("3-entry map: err = %v, want *arrayTooLongError counting 6 frames", err)
+	}
+	// 2^62 entries doubles past MaxInt64; it must still be refused
+	// rather than reaching make() with a wrapped count.
+	if _, err := readRespReply(bufio.NewReader(strings.NewReader("%4611686018427387904\r\n")), respLimits{MaxArray: 4}); !errors.As(err, &arrErr) {
+		t.Errorf("2^62-entry map: err = %v, want *arrayTooLongError", err)
+	}
+	if err := read("%-3\r\n"); err == nil {
+		t.Error("negative map count parsed, want framing error")
+	}
+	var depthErr *nestingTooDeepError
+	if err := read("*1\r\n*1\r\n*1\r\n:1\r\n"); !errors.As(err, &depthErr) {
+		t.Errorf("depth-3 array: err = %v, want *nestingTooDeepError", err)
+	}
+	if err := read("*1\r\n*1\r\n:1\r\n"); err != nil {
+		t.Errorf("depth-2 array within limit: %v", err)
+	}
+	if err := read("$16\r\n0123456789abcdef\r\n"); err != nil {
+		t.Errorf("bulk at limit: %v", err)
+	}
+}
+
+// TestReadRespReply_ReplyBudget pins the whole-reply budget: replies
+// whose every frame passes the per-frame limits are still refused once
+// their bytes or element count add up past it, and each reply on a
+// connection starts with a fresh budget.
+func TestReadRespReply_ReplyBudget(t *testing.T) {
+	lim := respLimits{MaxBulk: 16, MaxArray: 4, MaxDepth: 3, MaxReplyBytes: 64, MaxReplyElems: 10}
+	bulk16 := "$16\r\n0123456789abcdef\r\n"
+	pair := "*2\r\n:0\r\n:0\r\n"
+
+	var budgetErr *replyTooLargeError
+	if _, err := readRespReply(bufio.NewReader(strings.NewReader("*4\r\n"+strings.Repeat(bulk16, 4))), lim); !errors.As(err, &budgetErr) || budgetErr.MaxBytes != 64 {
+		t.Errorf("4 x 16-byte bulks: err = %v, want *replyTooLargeError on bytes", err)
+	}
+	if _, err := readRespReply(bufio.NewReader(strings.NewReader("*4\r\n"+strings.Repeat(pair, 4))), lim); !errors.As(err, &budgetErr) || budgetErr.Elems <= 10 {
+		t.Errorf("13 small frames: err = %v, want *replyTooLargeError on elements", err)
+	}
+
+	br := bufio.NewReader(strings.NewReader(strings.Repeat("*2\r\n"+bulk16+bulk16, 3)))
+	for i := range 3 {
+		if v, err := readRespReply(br, lim); err != nil || len(v.Elems) != 2 {
+			t.Fatalf("reply %d within budget = %+v, %v", i, v, err)
+		}
+	}
+}
+
+func TestReadRespReply_BulkMissingCRLF(t *testing.T) {
+	if _, err := readRespReply(bufio.NewReader(strings.NewReader("$3\r\nfooXX")), respLimits{}); err == nil {
+		t.Error("bulk without CRLF terminator parsed, want framing error")
+	}
+}
+
+// FuzzReadRespReply throws arbitrary bytes at the parser. The seed
+// corpus under testdata/fuzz/FuzzReadRespReply holds real replies from
+// the chain (stage payload results, HELLO 3, CLUSTER SHARDS, MONITOR,
+// push frames) plus one frame of every RESP2/RESP3 type. Properties:
+// never panic, never exceed the limits, and anything that parses must
+// encode to valid JSON.
+func FuzzReadRespReply(f *testing.F) {
+	for _, seed := range []string{
+		"+OK\r\n", "-ERR x\r\n", ":42\r\n", "$3\r\nfoo\r\n", "$-1\r\n", "*-1\r\n",
+		"*2\r\n:1\r\n$1\r\na\r\n", "_\r\n", ",1.5\r\n", "(123\r\n", "#t\r\n",
+		"=7\r\ntxt:abc\r\n", "!3\r\nERR\r\n", "%1\r\n+k\r\n+v\r\n", "~1\r\n+a\r\n",
+		">2\r\n+a\r\n+b\r\n:1\r\n", "|1\r\n+a\r\n+b\r\n:1\r\n",
+		"%4611686018427387904\r\n",
+	} {
+		f.Add([]byte(seed))
+	}
+	lim := respLimits{MaxBulk: 1 << 16, MaxArray: 1 << 10, MaxDepth: 8}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		v, err := readRespReply(bufio.NewReader(bytes.NewReader(in)), lim)
+		if err != nil {
+			return
+		}
+		if d := respDepth(v); d > lim.MaxDepth {
+			t.Fatalf("parsed depth %d > limit %d", d, lim.MaxDepth)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		if !json.Valid(b) {
+			t.Fatalf("Marshal produced invalid JSON %q", b)
+		}
+	})
+}
+
+func respDepth(v respValue) int {
+	d := 0
+	for _, e := range v.Elems {
+		d = max(d, respDepth(e))
+	}
+	if v.Elems != nil {
+		d++
+	}
+	return d
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultScripts is the registry of approved named Lua scripts. They
+// are SCRIPT LOADed at startup and invoked by SHA through
+// /api/cache/call/{name} — the shape real client libraries give the
+// atomic-counter pattern. Raw /api/cache/eval stays available next to
+// it, so a learned profile has to tell the two traffic shapes apart.
+var defaultScripts = map[string]string{
+	// incr: KEYS[1] += ARGV[1] (default 1).
+	"incr": `return redis.call("INCRBY", KEYS[1], tonumber(ARGV[1] or "1"))`,
+
+	// window: fixed-window hit counter. KEYS[1] counts hits, ARGV[1]
+	// is the window in seconds; returns the count in the current window.
+	"window": `local n = redis.call("INCR", KEYS[1])
+if n == 1 then redis.call("EXPIRE", KEYS[1], tonumber(ARGV[1] or "60")) end
+return n`,
+
+	// ratelimit: sliding-window limiter over a sorted set of hit
+	// timestamps. KEYS[1] is the set; ARGV is now (ms), window (ms),
+	// limit and a unique member for this hit. Returns {allowed (1/0),
+	// remaining, retry-after ms}.
+	"ratelimit": `local now, window, limit = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local n = redis.call("ZCARD", KEYS[1])
+if n < limit then
+  redis.call("ZADD", KEYS[1], now, ARGV[4])
+  redis.call("PEXPIRE", KEYS[1], window)
+  return {1, limit - n - 1, 0}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {0, 0, tonumber(oldest[2]) + window - now}`,
+
+	// cas: set KEYS[1] to ARGV[2] only if it currently equals ARGV[1].
+	// Returns 1 on swap, 0 otherwise.
+	"cas": `if redis.call("GET", KEYS[1]) == ARGV[1] then
+  redis.call("SET", KEYS[1], ARGV[2])
+  return 1
+end
+return 0`,
+}
+
+// namedScript is one registry entry. SHA is computed locally — it is
+// the same hex SHA1 redis returns from SCRIPT LOAD, so EVALSHA works
+// even when startup loading failed and the NOSCRIPT fallback has
+// since cached the body.
+type namedScript struct {
+	Name string `json:"name"`
+	SHA  string `json:"sha"`
+	Src  string `json:"-"`
+}
+
+type scriptRegistry struct {
+	scripts map[string]*namedScript
+}
+
+func newScriptRegistry(defs map[string]string) *scriptRegistry {
+	reg := &scriptRegistry{scripts: make(map[string]*namedScript, len(defs))}
+	for name, src := range defs {
+		sum := sha1.Sum([]byte(src))
+		reg.scripts[name] = &namedScript{Name: name, SHA: hex.EncodeToString(sum[:]), Src: src}
+	}
+	return reg
+}
+
+// Lookup returns the named script, or nil.
+func (reg *scriptRegistry) Lookup(name string) *namedScript {
+	return reg.scripts[name]
+}
+
+// List returns every script sorted by name.
+func (reg *scriptRegistry) List() []*namedScript {
+	out := make([]*namedScript, 0, len(reg.scripts))
+	for _, s := range reg.scripts {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Load pipelines SCRIPT LOAD for every registered script. Redis may
+// not be up yet when the frontend starts, so callers treat an error as
+// a warning — Call falls back to EVAL on NOSCRIPT anyway.
+func (reg *scriptRegistry) Load(ctx context.Context, rd redisClient) error {
+	list := reg.List()
+	cmds := make([][]string, len(list))
+	for i, s := range list {
+		cmds[i] = []string{"SCRIPT", "LOAD", s.Src}
+	}
+	replies, err := rd.Pipeline(ctx, cmds...)
+	if err != nil {
+		return err
+	}
+	for _, v := range replies {
+		if err := v.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Call runs the script by SHA. When redis answers NOSCRIPT (restart,
+// SCRIPT FLUSH, failover) it re-sends the body with EVAL, which also
+// re-caches it for the next EVALSHA.
+func (reg *scriptRegistry) Call(ctx context.Context, rd redisClient, s *namedScript, keys, args []string) (respValue, error) {
+	v, err := rd.Do(ctx, scriptCommand("EVALSHA", s.SHA, keys, args)...)
+	var re *redisError
+	if errors.As(err, &re) && strings.HasPrefix(re.msg, "NOSCRIPT") {
+		return rd.Do(ctx, scriptCommand("EVAL", s.Src, keys, args)...)
+	}
+	return v, err
+}
+
+// scriptCommand builds <verb> <script-or-sha> <numkeys> <keys...> <args...>,
+// the shape EVAL, EVALSHA and FCALL (with a function name) all share.
+func scriptCommand(verb, script string, keys, args []string) []string {
+	cmd := make([]string, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, verb, script, strconv.Itoa(len(keys)))
+	cmd = append(cmd, keys...)
+	return append(cmd, args...)
+}
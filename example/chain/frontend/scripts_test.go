@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// noscriptRedis answers EVALSHA with NOSCRIPT (as after a SCRIPT FLUSH
+// or a redis restart) and everything else with :1, recording the
+// command vectors it saw.
+type noscriptRedis struct{ cmds [][]string }
+
+func (n *noscriptRedis) Do(_ context.Context, args ...string) (respValue, error) {
+	n.cmds = append(n.cmds, args)
+	if args[0] == "EVALSHA" {
+		return respValue{}, &redisError{msg: "NOSCRIPT No matching script. Please use EVAL."}
+	}
+	return respValue{Kind: respInt, Int: 1}, nil
+}
+
+func (n *noscriptRedis) Pipeline(ctx context.Context, cmds ...[]string) ([]respValue, error) {
+	out := make([]respValue, len(cmds))
+	for i, c := range cmds {
+		out[i], _ = n.Do(ctx, c...)
+	}
+	return out, nil
+}
+
+func (n *noscriptRedis) Subscribe(context.Context, []string, []string, func(pubsubMessage)) error {
+	return errors.New("noscriptRedis: no pub/sub")
+}
+
+// TestScriptRegistry_SHAMatchesRedis pins the locally computed SHA to
+// the one redis documents for `return 1` — EVALSHA only works if the
+// two agree.
+func TestScriptRegistry_SHAMatchesRedis(t *testing.T) {
+	reg := newScriptRegistry(map[string]string{"one": "return 1"})
+	if got := reg.Lookup("one").SHA; got != "e0e1f9fabfc9d4800c877a703b823ac0578ff8db" {
+		t.Errorf("sha = %s, want redis' sha1 of the body", got)
+	}
+}
+
+// TestCacheCall_EvalshaFallsBackOnNoscript pins the registered-script
+// wire shape: EVALSHA first, then EVAL with the approved body when
+// redis has lost its script cache.
+func TestCacheCall_EvalshaFallsBackOnNoscript(t *testing.T) {
+	rd := &noscriptRedis{}
+	srv := newServer(nil, rd)
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/call/incr",
+		strings.NewReader(`{"keys":["chain:counter"],"args":["5"]}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reply":1`) {
+		t.Fatalf("status = %d body=%q, want 200 with reply 1", rec.Code, rec.Body.String())
+	}
+	if len(rd.cmds) != 2 {
+		t.Fatalf("redis saw %v, want EVALSHA then EVAL", rd.cmds)
+	}
+	sha := newScriptRegistry(defaultScripts).Lookup("incr").SHA
+	if got := strings.Join(rd.cmds[0], " "); got != "EVALSHA "+sha+" 1 chain:counter 5" {
+		t.Errorf("first cmd = %q", got)
+	}
+	if rd.cmds[1][0] != "EVAL" || rd.cmds[1][1] != defaultScripts["incr"] {
+		t.Errorf("fallback cmd = %q, want EVAL with the registered body", rd.cmds[1])
+	}
+}
+
+// TestCacheCall_UnknownScript404 pins that the call path only runs
+// registered names — ad-hoc Lua goes through /api/cache/eval instead.
+func TestCacheCall_UnknownScript404(t *testing.T) {
+	rd := &stubRedis{}
+	srv := newServer(nil, rd)
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/call/nope", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown script = %d, want 404", rec.Code)
+	}
+	if rd.lastCmd != nil {
+		t.Errorf("unknown script reached redis: %v", rd.lastCmd)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// sentinelRedis is the REDIS_MODE=sentinel client. It asks the
+// sentinels for the current master of a named group, keeps one pooled
+// respRedis for it, and re-resolves when the master stops answering or
+// turns into a replica (READONLY) after a failover.
+type sentinelRedis struct {
+	sentinels []string
+	master    string
+	opts      redisOptions
+
+	mu      sync.Mutex
+	current *respRedis
+}
+
+func newSentinelRedis(sentinels []string, master string, opts redisOptions) *sentinelRedis {
+	return &sentinelRedis{sentinels: sentinels, master: master, opts: opts}
+}
+
+func (s *sentinelRedis) Do(ctx context.Context, args ...string) (respValue, error) {
+	for attempt := 0; ; attempt++ {
+		m, err := s.resolve(ctx)
+		if err != nil {
+			return respValue{}, err
+		}
+		v, err := m.Do(ctx, args...)
+		if attempt == 0 && s.failedOver(ctx, err) {
+			s.invalidate(m)
+			continue
+		}
+		return v, err
+	}
+}
+
+func (s *sentinelRedis) Pipeline(ctx context.Context, cmds ...[]string) ([]respValue, error) {
+	for attempt := 0; ; attempt++ {
+		m, err := s.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		replies, err := m.Pipeline(ctx, cmds...)
+		demoted := err == nil && len(replies) > 0 && s.failedOver(ctx, replies[0].Err())
+		if attempt == 0 && (demoted || s.failedOver(ctx, err)) {
+			s.invalidate(m)
+			continue
+		}
+		return replies, err
+	}
+}
+
+// Subscribe listens on the current master. A failover ends it with
+// the connection error; subscribers are expected to reconnect, which
+// re-resolves the master.
+func (s *sentinelRedis) Subscribe(ctx context.Context, channels, patterns []string, fn func(pubsubMessage)) error {
+	m, err := s.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	err = m.Subscribe(ctx, channels, patterns, fn)
+	if s.failedOver(ctx, err) {
+		s.invalidate(m)
+	}
+	return err
+}
+
+// Monitor follows the current master.
+func (s *sentinelRedis) Monitor(ctx context.Context, fn func(monitorEntry)) error {
+	m, err := s.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return m.Monitor(ctx, fn)
+}
+
+// Inline sends the raw line to the current master.
+func (s *sentinelRedis) Inline(ctx context.Context, line string) (respValue, error) {
+	m, err := s.resolve(ctx)
+	if err != nil {
+		return respValue{}, err
+	}
+	return m.Inline(ctx, line)
+}
+
+// Stats reports the current master's pool; counters restart after a
+// failover.
+func (s *sentinelRedis) Stats() poolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return poolStats{}
+	}
+	return s.current.Stats()
+}
+
+// Close closes the current master's pool.
+func (s *sentinelRedis) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		return s.current.Close()
+	}
+	return nil
+}
+
+// resolve returns the pool for the current master, asking the
+// sentinels when there is none yet. The sentinels are queried without
+// s.mu held, so a slow or unreachable one doesn't stall Stats, Close
+// or callers that already have a master; when two calls race, the
+// first to finish wins and the other's pool is dropped.
+func (s *sentinelRedis) resolve(ctx context.Context) (*respRedis, error) {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+	if current != nil {
+		return current, nil
+	}
+	addr, err := s.askSentinels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := newRespRedis(addr, s.opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		_ = m.Close()
+		return s.current, nil
+	}
+	s.current = m
+	return m, nil
+}
+
+// askSentinels returns the master address from the first sentinel
+// that knows s.master.
+func (s *sentinelRedis) askSentinels(ctx context.Context) (string, error) {
+	var lastErr error
+	for _, addr := range s.sentinels {
+		// Sentinels speak plain RESP2 and usually have no ACL of their
+		// own; reuse only the transport settings.
+		sc := newRespRedis(addr, redisOptions{TLS: s.opts.TLS, DialTimeout: s.opts.DialTimeout, Timeout: s.opts.Timeout})
+		v, err := sc.Do(ctx, "SENTINEL", "GET-MASTER-ADDR-BY-NAME", s.master)
+		_ = sc.Close()
+		if err == nil && (v.Kind == respNil || len(v.Elems) != 2) {
+			err = fmt.Errorf("sentinel %s does not know master %q", addr, s.master)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return net.JoinHostPort(v.Elems[0].String(), v.Elems[1].String()), nil
+	}
+	return "", fmt.Errorf("sentinel: %w", lastErr)
+}
+
+// invalidate forgets m so the next call asks the sentinels again.
+func (s *sentinelRedis) invalidate(m *respRedis) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == m {
+		_ = m.Close()
+		s.current = nil
+	}
+}
+
+// failedOver reports errors that mean "this is no longer the master":
+// a READONLY reply from a demoted node, or a transport failure that
+// wasn't caused by the caller going away.
+func (s *sentinelRedis) failedOver(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var re *redisError
+	if errors.As(err, &re) {
+		return strings.HasPrefix(re.msg, "READONLY")
+	}
+	return true
+}
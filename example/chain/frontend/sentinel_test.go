@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSentinelRedis_ResolvesMaster pins the sentinel handshake: ask
+// for the named group's master, then talk to that address.
+func TestSentinelRedis_ResolvesMaster(t *testing.T) {
+	master, masterCmds := fakeRedis(t, []string{"+PONG\r\n"})
+	host, port, _ := net.SplitHostPort(master)
+	sentinel, sentinelCmds := fakeRedis(t, []string{fmt.Sprintf("*2\r\n%s%s", bulk(host), bulk(port))})
+
+	s := newSentinelRedis([]string{sentinel}, "chain", redisOptions{})
+	v, err := s.Do(context.Background(), "PING")
+	if err != nil || v.Str != "PONG" {
+		t.Fatalf("Do = %+v, %v; want PONG from the master", v, err)
+	}
+	s.Close()
+	if seen := <-sentinelCmds; strings.Join(seen[0], " ") != "SENTINEL GET-MASTER-ADDR-BY-NAME chain" {
+		t.Errorf("sentinel saw %q", seen)
+	}
+	if seen := <-masterCmds; len(seen) != 1 || seen[0][0] != "PING" {
+		t.Errorf("master saw %q, want PING", seen)
+	}
+}
+
+// TestSentinelRedis_ResolveDoesNotHoldLock pins that a sentinel which
+// never answers stalls only the call asking it, not Stats or Close.
+func TestSentinelRedis_ResolveDoesNotHoldLock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- c
+		}
+	}()
+
+	s := newSentinelRedis([]string{ln.Addr().String()}, "chain", redisOptions{Timeout: 5 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Do(ctx, "PING")
+		done <- err
+	}()
+	conn := <-accepted
+	defer conn.Close()
+
+	unblocked := make(chan struct{})
+	go func() {
+		s.Stats()
+		_ = s.Close()
+		close(unblocked)
+	}()
+	select {
+	case <-unblocked:
+	case <-time.After(time.Second):
+		t.Error("Stats/Close blocked behind the sentinel query")
+	}
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("Do succeeded without a sentinel answer")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// redisStreamer is implemented by clients that can report progress
+// while a command runs. /api/cache/eval/stream uses it when present;
+// other clients (cluster, sentinel, test stubs) go through Do and only
+// report "sent" before the final reply.
+type redisStreamer interface {
+	Stream(ctx context.Context, args []string, emit func(streamEvent)) (respValue, error)
+}
+
+// streamEvent is one step of a streamed command, rendered as one SSE
+// frame: Name becomes the event: line and Data, stamped with the time
+// the step happened, the data: line.
+type streamEvent struct {
+	Name string
+	Time time.Time
+	Data map[string]any
+}
+
+func newStreamEvent(name string, data map[string]any) streamEvent {
+	return streamEvent{Name: name, Time: time.Now(), Data: data}
+}
+
+// Stream is Do with progress: "connected" once a connection is checked
+// out (reused from the pool or freshly dialed and handshaken), "sent"
+// once the command is flushed, and "partial" for each element of an
+// array or set reply as it is parsed. Unlike Do there is no
+// opts.Timeout — a stage payload may legitimately run for minutes —
+// so only the caller's context bounds it.
+func (r *respRedis) Stream(ctx context.Context, args []string, emit func(streamEvent)) (respValue, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return respValue{}, err
+	}
+	emit(newStreamEvent("connected", map[string]any{"addr": r.addr, "reused": !c.lastUsed.IsZero()}))
+
+	deadline, _ := ctx.Deadline()
+	var v respValue
+	stopped, err := r.guard(ctx, c, deadline, func() error {
+		cmd := encodeCommand(args...)
+		if _, err := c.bw.Write(cmd); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		if err := c.bw.Flush(); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		emit(newStreamEvent("sent", map[string]any{"bytes": len(cmd)}))
+		var err error
+		v, err = readRespStream(c.br, r.opts.Limits, func(i int, e respValue) {
+			emit(newStreamEvent("partial", map[string]any{"index": i, "value": e}))
+		})
+		return err
+	})
+	r.put(c, err == nil && stopped)
+	if err != nil {
+		return respValue{}, err
+	}
+	return v, v.Err()
+}
+
+// streamCommand runs args through rd's Stream when it has one, else
+// through Do.
+func streamCommand(ctx context.Context, rd redisClient, args []string, emit func(streamEvent)) (respValue, error) {
+	if s, ok := rd.(redisStreamer); ok {
+		return s.Stream(ctx, args, emit)
+	}
+	emit(newStreamEvent("sent", nil))
+	return rd.Do(ctx, args...)
+}
+
+// registerStreamRoutes wires POST /api/cache/eval/stream: the same
+// request body and the same verbatim EVAL as /api/cache/eval, answered
+// as Server-Sent Events instead of one JSON document:
+//
+//	connected → sent → partial* → reply | error
+//
+// with a "waiting" event every heartbeat while redis stays silent, so
+// a long stage payload (the s6 DNS exfil loop) never looks like a hung
+// request. Every event carries "ts" (RFC 3339, UTC) and "elapsed_ms"
+// since the request arrived; the attack runner records them to line
+// stages up with alert timestamps.
+func registerStreamRoutes(mux *http.ServeMux, rd redisClient, heartbeat time.Duration) {
+	mux.HandleFunc("/api/cache/eval/stream", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := readEvalRequest(w, r)
+		if !ok {
+			return
+		}
+		sse := startSSE(w)
+
+		events := make(chan streamEvent, 16)
+		done := make(chan struct{})
+		var (
+			reply respValue
+			err   error
+		)
+		go func() {
+			defer close(done)
+			reply, err = streamCommand(r.Context(), rd, scriptCommand("EVAL", req.Script, req.Keys, req.Args),
+				func(ev streamEvent) { events <- ev })
+		}()
+
+		tick := time.NewTicker(heartbeat)
+		defer tick.Stop()
+		for {
+			select {
+			case ev := <-events:
+				sse.send(ev)
+				tick.Reset(heartbeat)
+			case <-tick.C:
+				sse.send(newStreamEvent("waiting", nil))
+			case <-done:
+				for len(events) > 0 {
+					sse.send(<-events)
+				}
+				if err != nil {
+					sse.send(newStreamEvent("error", map[string]any{"error": err.Error()}))
+				} else {
+					sse.send(newStreamEvent("reply", map[string]any{"reply": req.encode(reply)}))
+				}
+				return
+			}
+		}
+	})
+}
+
+// sseWriter frames events as text/event-stream and flushes each one
+// so it leaves the pod when it happens, not when the handler returns.
+type sseWriter struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	start time.Time
+	id    int
+}
+
+// startSSE commits the response as an event stream. Elapsed times
+// count from here.
+func startSSE(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // ingress-nginx: don't buffer
+	w.WriteHeader(http.StatusOK)
+	s := &sseWriter{w: w, rc: http.NewResponseController(w), start: time.Now()}
+	_ = s.rc.Flush()
+	return s
+}
+
+func (s *sseWriter) send(ev streamEvent) {
+	data := map[string]any{
+		"ts":         ev.Time.UTC().Format(time.RFC3339Nano),
+		"elapsed_ms": ev.Time.Sub(s.start).Milliseconds(),
+	}
+	for k, v := range ev.Data {
+		data[k] = v
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{"ts": data["ts"].(string), "error": err.Error()})
+	}
+	s.id++
+	_, _ = fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.id, ev.Name, b)
+	_ = s.rc.Flush()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one parsed text/event-stream frame.
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+func readSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var out []sseEvent
+	sc := bufio.NewScanner(strings.NewReader(body))
+	var ev sseEvent
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatalf("bad data line %q: %v", line, err)
+			}
+		case line == "":
+			out = append(out, ev)
+			ev = sseEvent{}
+		}
+	}
+	return out
+}
+
+func eventNames(evs []sseEvent) string {
+	names := make([]string, len(evs))
+	for i, ev := range evs {
+		names[i] = ev.name
+	}
+	return strings.Join(names, " ")
+}
+
+// TestEvalStream_RespRedisProgress pins the event sequence against a
+// real respRedis: connected → sent → one partial per array element →
+// reply, every event timestamped, and the script still forwarded
+// verbatim.
+func TestEvalStream_RespRedisProgress(t *testing.T) {
+	addr, cmds := fakeRedis(t, []string{"*2\r\n$4\r\nstep\r\n:2\r\n"})
+	rd := newRespRedis(addr, redisOptions{})
+	srv := newServer(nil, rd)
+
+	script := `return {"step", 2}`
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/eval/stream", strings.NewReader(`{"script":`+jsonString(script)+`}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	rd.Close()
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	evs := readSSE(t, rec.Body.String())
+	if got := eventNames(evs); got != "connected sent partial partial reply" {
+		t.Fatalf("events = %q; body=%s", got, rec.Body.String())
+	}
+	for _, ev := range evs {
+		if _, err := time.Parse(time.RFC3339Nano, ev.data["ts"].(string)); err != nil {
+			t.Errorf("%s: ts: %v", ev.name, err)
+		}
+	}
+	if evs[3].data["value"] != float64(2) {
+		t.Errorf("second partial = %v, want 2", evs[3].data["value"])
+	}
+	if seen := <-cmds; len(seen) != 1 || seen[0][1] != script {
+		t.Errorf("redis saw %q, want the script verbatim", seen)
+	}
+}
+
+// slowRedis holds every command until release is closed.
+type slowRedis struct {
+	stubRedis
+	release chan struct{}
+}
+
+func (s *slowRedis) Do(ctx context.Context, args ...string) (respValue, error) {
+	<-s.release
+	return s.stubRedis.Do(ctx, args...)
+}
+
+// TestEvalStream_HeartbeatWhileWaiting pins that a silent redis
+// produces "waiting" events instead of a dead connection, and that a
+// client without Stream still gets sent → reply.
+func TestEvalStream_HeartbeatWhileWaiting(t *testing.T) {
+	rd := &slowRedis{stubRedis: stubRedis{reply: respValue{Kind: respSimple, Str: "OK"}}, release: make(chan struct{})}
+	time.AfterFunc(50*time.Millisecond, func() { close(rd.release) })
+	srv := newServer(nil, rd, withStreamHeartbeat(5*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/eval/stream", strings.NewReader(`{"script":"return 'OK'"}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	got := eventNames(readSSE(t, rec.Body.String()))
+	if !strings.HasPrefix(got, "sent waiting") || !strings.HasSuffix(got, "waiting reply") {
+		t.Errorf("events = %q, want sent, waiting…, reply", got)
+	}
+}
+
+func TestEvalStream_RedisErrorEvent(t *testing.T) {
+	rd := &stubRedis{err: &redisError{msg: "ERR user_script:1: boom"}}
+	srv := newServer(nil, rd)
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/eval/stream", strings.NewReader(`{"script":"error()"}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	evs := readSSE(t, rec.Body.String())
+	last := evs[len(evs)-1]
+	if last.name != "error" || !strings.Contains(last.data["error"].(string), "boom") {
+		t.Errorf("last event = %+v, want error carrying the redis message", last)
+	}
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
